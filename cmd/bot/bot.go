@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// bot is one computer player. It joins like a client and asks its strategy
+// for orders whenever a turn starts.
+type bot struct {
+	username string
+	out      io.Writer
+	strategy Strategy
+	state    *gamelogic.GameState
+	rng      *rand.Rand
+
+	publisher pubsub.Publisher
+	names     routing.Names
+	subs      []*pubsub.Subscription
+
+	mu      sync.Mutex
+	enemies map[gamelogic.UnitRef]gamelogic.Unit
+	stats   botStats
+}
+
+type botStats struct {
+	orders   int
+	rejected int
+	wars     int
+}
+
+// newBot makes a bot that writes its game output to out.
+func newBot(username string, strategy Strategy, board *gamelogic.Board, seed int64, out io.Writer) *bot {
+	state := gamelogic.NewGameState(username)
+	state.SetBoard(board)
+	state.SetOutput(out)
+	return &bot{
+		username: username,
+		out:      out,
+		strategy: strategy,
+		state:    state,
+		rng:      rand.New(rand.NewSource(seed)),
+		enemies:  map[gamelogic.UnitRef]gamelogic.Unit{},
+	}
+}
+
+// start declares the bot's queues, subscribes to the game and joins it,
+// using the exchanges names gives.
+func (b *bot) start(ctx context.Context, broker pubsub.Broker, names routing.Names) error {
+	b.names = names
+	chnl, err := broker.Channel()
+	if err != nil {
+		return fmt.Errorf("can't create a new channel: %v", err)
+	}
+	if err := pubsub.ApplyTopology(chnl, routing.ClientTopology(names, b.username)); err != nil {
+		return fmt.Errorf("can't declare topology: %v", err)
+	}
+	b.publisher, err = pubsub.NewConfirmingPublisher(chnl, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("can't enable publisher confirms: %v", err)
+	}
+
+	pauseSub, err := pubsub.SubscribeJSON(ctx, broker, names.Direct, routing.PauseQueue(b.username), routing.PauseKey, pubsub.TransientQueue, b.handlePause, pubsub.WithOutput(b.out), pubsub.WithNames(names))
+	if err != nil {
+		return fmt.Errorf("could not subscribe to pause: %v", err)
+	}
+	turnSub, err := pubsub.SubscribeJSON(ctx, broker, names.Direct, routing.TurnQueue(b.username), routing.TurnKey, pubsub.TransientQueue, b.handleTurn, pubsub.WithOutput(b.out), pubsub.WithNames(names))
+	if err != nil {
+		return fmt.Errorf("could not subscribe to turns: %v", err)
+	}
+	eventSub, err := pubsub.SubscribeJSON(ctx, broker, names.Topic, routing.EventsQueue(b.username), routing.AllKeys(routing.EventsPrefix), pubsub.TransientQueue, b.handleEvent, pubsub.WithOutput(b.out), pubsub.WithNames(names))
+	if err != nil {
+		return fmt.Errorf("could not subscribe to game events: %v", err)
+	}
+	b.subs = []*pubsub.Subscription{pauseSub, turnSub, eventSub}
+
+	return b.publish(gamelogic.Intent{Kind: gamelogic.IntentJoin, Username: b.username})
+}
+
+func (b *bot) stop() {
+	for _, sub := range b.subs {
+		sub.Close()
+	}
+}
+
+func (b *bot) handlePause(ps routing.PlayingState) pubsub.AckType {
+	b.state.HandlePause(ps)
+	return pubsub.Ack
+}
+
+func (b *bot) handleTurn(ts routing.TurnState) pubsub.AckType {
+	b.state.HandleTurn(ts)
+	if ts.Phase == routing.TurnStart {
+		// give orders off the consumer so queued confirmations aren't held
+		// up behind our own publishes
+		go b.act(ts.Turn)
+	}
+	return pubsub.Ack
+}
+
+func (b *bot) handleEvent(ev gamelogic.GameEvent) pubsub.AckType {
+	b.state.ApplyEvent(ev)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch ev.Kind {
+	case gamelogic.EventSpawned, gamelogic.EventMoved:
+		if ev.Username != b.username {
+			for _, u := range ev.Units {
+				b.enemies[u.Ref()] = u
+			}
+		}
+	case gamelogic.EventWar:
+		if ev.War == nil {
+			return pubsub.Ack
+		}
+		if ev.War.Attacker == b.username || ev.War.Defender == b.username {
+			b.stats.wars++
+		}
+		for _, ref := range ev.Casualties {
+			delete(b.enemies, ref)
+		}
+		for _, battle := range ev.War.Battles {
+			for _, u := range append(battle.AttackerSurvivors, battle.DefenderSurvivors...) {
+				if u.Owner != b.username {
+					b.enemies[u.Ref()] = u
+				}
+			}
+		}
+	case gamelogic.EventRejected:
+		if ev.Username == b.username {
+			b.stats.rejected++
+		}
+	}
+	return pubsub.Ack
+}
+
+func (b *bot) act(turn int) {
+	b.mu.Lock()
+	view := View{
+		Turn:  turn,
+		Me:    b.state.GetPlayerSnap(),
+		Board: b.state.Board(),
+	}
+	for _, u := range b.enemies {
+		view.Enemies = append(view.Enemies, u)
+	}
+	cmds := b.strategy.Orders(view, b.rng)
+	b.mu.Unlock()
+
+	for _, cmd := range cmds {
+		var intent gamelogic.Intent
+		var err error
+		switch cmd[0] {
+		case "spawn":
+			intent, err = b.state.CommandSpawn(cmd)
+		case "move":
+			intent, err = b.state.CommandMove(cmd)
+		default:
+			err = fmt.Errorf("unknown command %q", cmd[0])
+		}
+		if err == nil {
+			err = b.publish(intent)
+		}
+		if err != nil {
+			log.Printf("%s: %v: %v", b.username, cmd, err)
+		}
+	}
+}
+
+func (b *bot) publish(intent gamelogic.Intent) error {
+	err := pubsub.PublishJSON(b.publisher, b.names.Topic, routing.IntentKey(b.username), intent)
+	if err == nil && intent.Kind != gamelogic.IntentJoin {
+		b.mu.Lock()
+		b.stats.orders++
+		b.mu.Unlock()
+	}
+	return err
+}
+
+func (b *bot) summary() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.state.GetPlayerSnap()
+	return fmt.Sprintf("%-12s units %3d, gold %4d, orders %4d, rejected %3d, wars %3d",
+		b.username, len(p.Units), p.Treasury, b.stats.orders, b.stats.rejected, b.stats.wars)
+}
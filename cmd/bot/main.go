@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gameserver"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub/memory"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func main() {
+	brokerKind := flag.String("broker", "memory", "memory to play a self-contained game, or amqp to join a running server")
+	botList := flag.String("bots", "random,rusher,turtle,greedy", "comma separated strategies, one bot each: "+strings.Join(strategyNames(), ", "))
+	turnLength := flag.Duration("turn", 2*time.Second, "turn length of the in-process server with -broker memory")
+	duration := flag.Duration("duration", 0, "stop after this long, 0 to run until interrupted")
+	seed := flag.Int64("seed", 0, "seed for the bots and the in-process server's dice, random if 0")
+	verbose := flag.Bool("v", false, "print every bot's game output")
+	cfg := config.MustLoad(flag.CommandLine, os.Args[1:])
+
+	board, err := cfg.LoadBoard()
+	if err != nil {
+		log.Fatalf("can't load board: %v", err)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Printf("seed: %d", *seed)
+
+	// a handful of bots all printing their game state is unreadable; keep
+	// their output quiet and report through the log unless asked
+	out := io.Discard
+	if *verbose {
+		out = os.Stdout
+	}
+
+	bots := []*bot{}
+	for i, name := range strings.Split(*botList, ",") {
+		name = strings.TrimSpace(name)
+		newStrategy, ok := strategies[name]
+		if !ok {
+			log.Fatalf("unknown strategy %q, want one of %s", name, strings.Join(strategyNames(), ", "))
+		}
+		bots = append(bots, newBot(fmt.Sprintf("%s-%d", name, i+1), newStrategy(), board, *seed+int64(i), out))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		ctx, stop = context.WithTimeout(ctx, *duration)
+		defer stop()
+	}
+
+	var broker pubsub.Broker
+	switch *brokerKind {
+	case "memory":
+		broker, err = memory.NewBroker().Dial()
+	case "amqp":
+		var dial pubsub.Dialer
+		if dial, err = cfg.Dialer(); err == nil {
+			broker, err = pubsub.NewManagedBroker(dial)
+		}
+	default:
+		log.Fatalf("unknown broker %q, want memory or amqp", *brokerKind)
+	}
+	if err != nil {
+		log.Fatalf("%s connection error: %v", *brokerKind, err)
+	}
+	defer broker.Close()
+
+	names := cfg.Names()
+	if *brokerKind == "memory" {
+		serverSub, err := startServer(ctx, broker, names, gamelogic.NewWorld(board, gamelogic.NewDice(*seed)), *turnLength, out)
+		if err != nil {
+			log.Fatalf("can't start the in-process server: %v", err)
+		}
+		defer serverSub.Close()
+	}
+
+	for _, b := range bots {
+		if err := b.start(ctx, broker, names); err != nil {
+			log.Fatalf("%s couldn't join: %v", b.username, err)
+		}
+		defer b.stop()
+	}
+	log.Printf("%d bots playing", len(bots))
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		select {
+		case <-ticker.C:
+			printSummary(bots)
+		case <-ctx.Done():
+		}
+	}
+	log.Println("bots are shutting down...")
+	printSummary(bots)
+}
+
+// startServer runs the authoritative game server against broker, the way
+// cmd/server does, so bots can play without any outside infrastructure.
+func startServer(ctx context.Context, broker pubsub.Broker, names routing.Names, world *gamelogic.World, turnLength time.Duration, out io.Writer) (*pubsub.Subscription, error) {
+	chnl, err := broker.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := pubsub.ApplyTopology(chnl, routing.ServerTopology(names)); err != nil {
+		return nil, err
+	}
+	sub, err := gameserver.SubscribeIntents(ctx, broker, names, world, chnl, pubsub.WithOutput(out))
+	if err != nil {
+		return nil, err
+	}
+	go gameserver.RunTurns(ctx, world, chnl, names, turnLength, "")
+	return sub, nil
+}
+
+func printSummary(bots []*bot) {
+	for _, b := range bots {
+		log.Println(b.summary())
+	}
+}
+
+func strategyNames() []string {
+	names := []string{}
+	for name := range strategies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"strconv"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// Strategy decides a bot's orders at the start of every turn. Orders are
+// client commands, e.g. {"spawn", "europe", "infantry"} or
+// {"move", "asia", "1", "2"}, and go through the same checks as a human's.
+type Strategy interface {
+	Orders(v View, rng *rand.Rand) [][]string
+}
+
+var strategies = map[string]func() Strategy{
+	"random": func() Strategy { return randomStrategy{} },
+	"rusher": func() Strategy { return rusherStrategy{} },
+	"turtle": func() Strategy { return turtleStrategy{} },
+	"greedy": func() Strategy { return greedyStrategy{} },
+}
+
+// View is what a bot knows at the start of a turn: its own player and the
+// last place it saw each enemy unit.
+type View struct {
+	Turn    int
+	Me      gamelogic.Player
+	Enemies []gamelogic.Unit
+	Board   *gamelogic.Board
+}
+
+func (v View) controllers() map[gamelogic.Location]string {
+	players := map[string]*gamelogic.Player{}
+	for _, u := range v.Enemies {
+		p, ok := players[u.Owner]
+		if !ok {
+			p = &gamelogic.Player{Username: u.Owner, Units: map[int]gamelogic.Unit{}}
+			players[u.Owner] = p
+		}
+		p.Units[u.ID] = u
+	}
+	all := []gamelogic.Player{v.Me}
+	for _, p := range players {
+		all = append(all, *p)
+	}
+	return gamelogic.Controllers(all)
+}
+
+// spawnLocations are the locations the server should let us spawn in.
+func (v View) spawnLocations() []gamelogic.Location {
+	controllers := v.controllers()
+	locations := []gamelogic.Location{}
+	for _, loc := range v.Board.Locations() {
+		if gamelogic.CanSpawnAt(controllers, v.Me.Username, loc) == nil {
+			locations = append(locations, loc)
+		}
+	}
+	return locations
+}
+
+// idle returns our units that aren't marching anywhere, by ID.
+func (v View) idle() []gamelogic.Unit {
+	units := []gamelogic.Unit{}
+	for _, u := range v.Me.Units {
+		if len(u.Path) == 0 {
+			units = append(units, u)
+		}
+	}
+	slices.SortFunc(units, func(a, b gamelogic.Unit) int { return a.ID - b.ID })
+	return units
+}
+
+// armies groups units by location.
+func armies(units []gamelogic.Unit) map[gamelogic.Location][]gamelogic.Unit {
+	groups := map[gamelogic.Location][]gamelogic.Unit{}
+	for _, u := range units {
+		groups[u.Location] = append(groups[u.Location], u)
+	}
+	return groups
+}
+
+func sortedLocations[V any](m map[gamelogic.Location]V) []gamelogic.Location {
+	locs := make([]gamelogic.Location, 0, len(m))
+	for loc := range m {
+		locs = append(locs, loc)
+	}
+	slices.Sort(locs)
+	return locs
+}
+
+func (v View) enemyPower() map[gamelogic.Location]int {
+	power := map[gamelogic.Location]int{}
+	for loc, units := range armies(v.Enemies) {
+		power[loc] = v.Board.PowerLevel(units)
+	}
+	return power
+}
+
+// distance is the movement cost from one location to another, or -1 if
+// there is no way there.
+func (v View) distance(from, to gamelogic.Location) int {
+	if from == to {
+		return 0
+	}
+	path, err := v.Board.Path(from, to)
+	if err != nil {
+		return -1
+	}
+	return v.Board.TurnsToArrive(gamelogic.Unit{Rank: gamelogic.RankInfantry, Location: from, Path: path})
+}
+
+// spend orders as many units of the first affordable rank in prefer as the
+// treasury allows, all in loc.
+func spend(v View, loc gamelogic.Location, prefer ...gamelogic.UnitRank) [][]string {
+	cmds := [][]string{}
+	gold := v.Me.Treasury
+	for {
+		i := slices.IndexFunc(prefer, func(r gamelogic.UnitRank) bool { return v.Board.Cost(r) <= gold })
+		if i < 0 || v.Board.Cost(prefer[i]) == 0 {
+			return cmds
+		}
+		gold -= v.Board.Cost(prefer[i])
+		cmds = append(cmds, []string{"spawn", string(loc), string(prefer[i])})
+	}
+}
+
+func move(to gamelogic.Location, units ...gamelogic.Unit) []string {
+	cmd := []string{"move", string(to)}
+	for _, u := range units {
+		cmd = append(cmd, strconv.Itoa(u.ID))
+	}
+	return cmd
+}
+
+// randomStrategy spawns and wanders about aimlessly.
+type randomStrategy struct{}
+
+func (randomStrategy) Orders(v View, rng *rand.Rand) [][]string {
+	cmds := [][]string{}
+	if locs := v.spawnLocations(); len(locs) > 0 && rng.Intn(2) == 0 {
+		ranks := gamelogic.Ranks()
+		rng.Shuffle(len(ranks), func(i, j int) { ranks[i], ranks[j] = ranks[j], ranks[i] })
+		if spawns := spend(v, locs[rng.Intn(len(locs))], ranks...); len(spawns) > 0 {
+			cmds = append(cmds, spawns[0])
+		}
+	}
+	for _, u := range v.idle() {
+		if rng.Intn(3) != 0 {
+			continue
+		}
+		if next := v.Board.Neighbours(u.Location); len(next) > 0 {
+			cmds = append(cmds, move(next[rng.Intn(len(next))], u))
+		}
+	}
+	return cmds
+}
+
+// rusherStrategy builds fast units and throws everything at the nearest
+// enemy, or at the richest land nobody holds if it hasn't seen one.
+type rusherStrategy struct{}
+
+func (rusherStrategy) Orders(v View, rng *rand.Rand) [][]string {
+	cmds := [][]string{}
+	if locs := v.spawnLocations(); len(locs) > 0 {
+		cmds = append(cmds, spend(v, locs[rng.Intn(len(locs))], gamelogic.RankCavalry, gamelogic.RankInfantry)...)
+	}
+
+	targets := sortedLocations(v.enemyPower())
+	if len(targets) == 0 {
+		controllers := v.controllers()
+		for _, loc := range v.Board.Locations() {
+			if controllers[loc] != v.Me.Username {
+				targets = append(targets, loc)
+			}
+		}
+		slices.SortStableFunc(targets, func(a, b gamelogic.Location) int { return v.Board.Income(b) - v.Board.Income(a) })
+		targets = targets[:min(len(targets), 1)]
+	}
+
+	groups := armies(v.idle())
+	for _, from := range sortedLocations(groups) {
+		units := groups[from]
+		best, bestDist := gamelogic.Location(""), -1
+		for _, to := range targets {
+			d := v.distance(from, to)
+			if d > 0 && (bestDist < 0 || d < bestDist) {
+				best, bestDist = to, d
+			}
+		}
+		if best != "" {
+			cmds = append(cmds, move(best, units...))
+		}
+	}
+	return cmds
+}
+
+// turtleStrategy digs in: it masses artillery in one home location and
+// pulls every stray unit back there.
+type turtleStrategy struct{}
+
+func (turtleStrategy) Orders(v View, rng *rand.Rand) [][]string {
+	groups := armies(v.idle())
+	home := gamelogic.Location("")
+	for _, loc := range sortedLocations(groups) {
+		if home == "" || len(groups[loc]) > len(groups[home]) {
+			home = loc
+		}
+	}
+	if home == "" || gamelogic.CanSpawnAt(v.controllers(), v.Me.Username, home) != nil {
+		locs := v.spawnLocations()
+		if len(locs) == 0 {
+			return nil
+		}
+		home = locs[rng.Intn(len(locs))]
+	}
+
+	cmds := spend(v, home, gamelogic.RankArtillery, gamelogic.RankCavalry, gamelogic.RankInfantry)
+	for _, loc := range sortedLocations(groups) {
+		if loc != home && v.distance(loc, home) > 0 {
+			cmds = append(cmds, move(home, groups[loc]...))
+		}
+	}
+	return cmds
+}
+
+// greedyStrategy buys the most power per gold and only fights battles its
+// numbers say it will win, otherwise grabbing the richest free land nearby.
+type greedyStrategy struct{}
+
+func (greedyStrategy) Orders(v View, rng *rand.Rand) [][]string {
+	ranks := gamelogic.Ranks()
+	slices.SortStableFunc(ranks, func(a, b gamelogic.UnitRank) int {
+		// compare power per gold without dividing: a/ca vs b/cb
+		return v.Board.RankPower(b)*max(v.Board.Cost(a), 1) - v.Board.RankPower(a)*max(v.Board.Cost(b), 1)
+	})
+
+	cmds := [][]string{}
+	locs := v.spawnLocations()
+	if len(locs) > 0 {
+		slices.SortStableFunc(locs, func(a, b gamelogic.Location) int { return v.Board.Income(b) - v.Board.Income(a) })
+		cmds = append(cmds, spend(v, locs[0], ranks...)...)
+	}
+
+	enemies := v.enemyPower()
+	controllers := v.controllers()
+	groups := armies(v.idle())
+	for _, from := range sortedLocations(groups) {
+		units := groups[from]
+		mine := v.Board.PowerLevel(units)
+		best, bestScore := gamelogic.Location(""), 0
+		for _, to := range v.Board.Neighbours(from) {
+			score := 0
+			switch enemy, ok := enemies[to]; {
+			case ok && enemy < mine:
+				score = v.Board.Income(to) + mine - enemy
+			case !ok && controllers[to] == "":
+				score = v.Board.Income(to)
+			}
+			if score > bestScore {
+				best, bestScore = to, score
+			}
+		}
+		// keep the army where it is if it is holding better land
+		if best != "" && (controllers[from] != v.Me.Username || bestScore > v.Board.Income(from)) {
+			cmds = append(cmds, move(best, units...))
+		}
+	}
+	return cmds
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// TestStrategyOrders checks every strategy only gives orders the client
+// accepts, both for a new player and for one with an army on the road.
+func TestStrategyOrders(t *testing.T) {
+	board := gamelogic.DefaultBoard()
+	unit := func(id int, rank gamelogic.UnitRank, loc gamelogic.Location, path ...gamelogic.Location) gamelogic.Unit {
+		return gamelogic.Unit{ID: id, Owner: "bot", Rank: rank, Health: board.MaxHealth(rank), Location: loc, Path: path}
+	}
+	views := map[string]View{
+		"new player": {
+			Turn: 1,
+			Me:   gamelogic.Player{Username: "bot", Units: map[int]gamelogic.Unit{}, Treasury: 10},
+		},
+		"army in the field": {
+			Turn: 5,
+			Me: gamelogic.Player{
+				Username:   "bot",
+				Treasury:   12,
+				NextUnitID: 5,
+				Units: map[int]gamelogic.Unit{
+					1: unit(1, gamelogic.RankInfantry, "europe"),
+					2: unit(2, gamelogic.RankCavalry, "europe"),
+					3: unit(3, gamelogic.RankArtillery, "asia"),
+					4: unit(4, gamelogic.RankInfantry, "africa", "antarctica"),
+				},
+			},
+			Enemies: []gamelogic.Unit{
+				{ID: 1, Owner: "rival", Rank: gamelogic.RankInfantry, Health: 5, Location: "americas"},
+				{ID: 2, Owner: "rival", Rank: gamelogic.RankCavalry, Health: 10, Location: "australia"},
+			},
+		},
+	}
+
+	for name := range strategies {
+		for viewName, view := range views {
+			t.Run(name+"/"+viewName, func(t *testing.T) {
+				view.Board = board
+				state := gamelogic.NewGameState("bot")
+				state.SetOutput(io.Discard)
+				me := view.Me
+				state.ApplyEvent(gamelogic.GameEvent{Kind: gamelogic.EventSync, Username: "bot", Turn: view.Turn, TurnOpen: true, Player: &me})
+
+				strategy := strategies[name]()
+				rng := rand.New(rand.NewSource(1))
+				given := 0
+				// the random strategy may do nothing on any one turn
+				for range 10 {
+					spent := 0
+					for _, cmd := range strategy.Orders(view, rng) {
+						given++
+						var err error
+						switch cmd[0] {
+						case "spawn":
+							_, err = state.CommandSpawn(cmd)
+							loc := gamelogic.Location(cmd[1])
+							if err == nil {
+								err = gamelogic.CanSpawnAt(view.controllers(), "bot", loc)
+							}
+							spent += board.Cost(gamelogic.UnitRank(cmd[2]))
+						case "move":
+							_, err = state.CommandMove(cmd)
+						default:
+							t.Fatalf("unknown command %v", cmd)
+						}
+						if err != nil {
+							t.Errorf("order %v: %v", cmd, err)
+						}
+					}
+					if spent > view.Me.Treasury {
+						t.Errorf("spent %d gold out of %d", spent, view.Me.Treasury)
+					}
+				}
+				if given == 0 {
+					t.Error("no orders in ten turns")
+				}
+			})
+		}
+	}
+}
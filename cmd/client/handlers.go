@@ -3,11 +3,11 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
-	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 func handlerPause(gs *gamelogic.GameState) func(routing.PlayingState) pubsub.AckType {
@@ -18,57 +18,74 @@ func handlerPause(gs *gamelogic.GameState) func(routing.PlayingState) pubsub.Ack
 	}
 }
 
-func handlerMove(gs *gamelogic.GameState, publishCh *amqp.Channel) func(gamelogic.ArmyMove) pubsub.AckType {
-	return func(move gamelogic.ArmyMove) pubsub.AckType {
+func handlerTurn(gs *gamelogic.GameState) func(routing.TurnState) pubsub.AckType {
+	return func(ts routing.TurnState) pubsub.AckType {
 		defer fmt.Print("> ")
+		gs.HandleTurn(ts)
+		return pubsub.Ack
+	}
+}
 
-		moveOutCome := gs.HandleMove(move)
-		switch moveOutCome {
-		case gamelogic.MoveOutcomeSamePlayer:
-			return pubsub.NackDiscard
-		case gamelogic.MoveOutComeSafe:
-			return pubsub.Ack
-
-		case gamelogic.MoveOutcomeMakeWar:
-			if err := pubsub.PublishJSON(
-				publishCh,
-				string(routing.ExchangePerilTopic),
-				routing.WarRecognitionsPrefix+"."+gs.GetUsername(),
-				gamelogic.RecognitionOfWar{
-					Attacker: move.Player,
-					Defender: gs.GetPlayerSnap(),
-				},
-			); err != nil {
-				slog.Error("publishing war message", "error", err)
-				return pubsub.NackRequeue
-			}
+func handlerEvent(gs *gamelogic.GameState, publisher pubsub.Publisher, exchange string) func(gamelogic.GameEvent) pubsub.AckType {
+	return func(ev gamelogic.GameEvent) pubsub.AckType {
+		defer fmt.Print("> ")
+		gs.ApplyEvent(ev)
 
+		// both sides see the war; only the attacker logs it
+		if ev.Kind != gamelogic.EventWar || ev.War == nil || ev.War.Attacker != gs.GetUsername() {
 			return pubsub.Ack
 		}
+		msg := ""
+		if winner, loser, draw := ev.War.Outcome(); draw {
+			msg = fmt.Sprintf("A war between %s and %s resulted in a draw", ev.War.Attacker, ev.War.Defender)
+		} else {
+			msg = fmt.Sprintf("%s won a war against %s", winner, loser)
+		}
+		if casualties := ev.War.Casualties(); len(casualties) > 0 {
+			msg += fmt.Sprintf(", losing %v", casualties)
+		}
+		return publishWarLog(publisher, exchange, gs.GetUsername(), msg)
+	}
+}
 
-		fmt.Println("error: unknown move outcome")
-		return pubsub.NackDiscard
+// publishWarLog records a resolved war in the server's game log. The war
+// has already been applied, so a failed log is only reported; requeueing
+// the event would apply the war twice.
+func publishWarLog(publisher pubsub.Publisher, exchange, username, msg string) pubsub.AckType {
+	if err := publishGameLog(publisher, exchange, username, msg); err != nil {
+		slog.Error("publishing war log", "error", err, "message", msg)
 	}
+	return pubsub.Ack
 }
 
-func handlerWar(gs *gamelogic.GameState) func(dw gamelogic.RecognitionOfWar) pubsub.AckType {
-	return func(dw gamelogic.RecognitionOfWar) pubsub.AckType {
-		defer fmt.Print("> ")
-		warOutcome, _, _ := gs.HandleWar(dw)
-		switch warOutcome {
-		case gamelogic.WarOutcomeNotInvolved:
-			return pubsub.NackRequeue
-		case gamelogic.WarOutcomeNoUnits:
-			return pubsub.NackDiscard
-		case gamelogic.WarOutcomeOpponentWon:
-			return pubsub.Ack
-		case gamelogic.WarOutcomeYouWon:
-			return pubsub.Ack
-		case gamelogic.WarOutcomeDraw:
-			return pubsub.Ack
-		}
+func publishGameLog(publisher pubsub.Publisher, exchange, username, msg string) error {
+	return pubsub.PublishGob(
+		publisher,
+		exchange,
+		routing.GameLogKey(username),
+		routing.GameLog{
+			CurrentTime: time.Now(),
+			Message:     msg,
+			Username:    username,
+		},
+	)
+}
 
-		fmt.Println("error: unknown war outcome")
-		return pubsub.NackDiscard
-	}
+// publishJoin asks the server for our player. Its answer replaces whatever
+// we restored from a saved game; a player it has never seen starts with the
+// starting treasury and no units.
+func publishJoin(publisher pubsub.Publisher, exchange string, gs *gamelogic.GameState) error {
+	return publishIntent(publisher, exchange, gamelogic.Intent{
+		Kind:     gamelogic.IntentJoin,
+		Username: gs.GetUsername(),
+	})
+}
+
+func publishIntent(publisher pubsub.Publisher, exchange string, intent gamelogic.Intent) error {
+	return pubsub.PublishJSON(
+		publisher,
+		exchange,
+		routing.IntentKey(intent.Username),
+		intent,
+	)
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub/memory"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestHandlerEventLogsWars(t *testing.T) {
+	war := &gamelogic.WarResult{
+		Attacker: "alice",
+		Defender: "bob",
+		Battles: []gamelogic.BattleResult{{
+			Location:       "europe",
+			DefenderLosses: []gamelogic.UnitRef{{Owner: "bob", ID: 1}},
+			Winner:         "alice",
+			Loser:          "bob",
+		}},
+	}
+	tests := []struct {
+		name     string
+		username string
+		ev       gamelogic.GameEvent
+		wantLog  string
+	}{
+		{
+			name:     "attacker logs the war",
+			username: "alice",
+			ev:       gamelogic.GameEvent{Kind: gamelogic.EventWar, Username: "alice", War: war},
+			wantLog:  "alice won a war against bob, losing [bob#1]",
+		},
+		{
+			name:     "defender doesn't",
+			username: "bob",
+			ev:       gamelogic.GameEvent{Kind: gamelogic.EventWar, Username: "alice", War: war},
+		},
+		{
+			name:     "nor does anything else",
+			username: "alice",
+			ev:       gamelogic.GameEvent{Kind: gamelogic.EventIncome, Username: "alice", Income: 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := memory.NewBroker()
+			conn := b.Connect()
+			chnl, _, err := pubsub.DeclareAndBind(conn, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), pubsub.DurableQueue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer chnl.Close()
+
+			state := gamelogic.NewGameState(tc.username)
+			state.SetOutput(io.Discard)
+			if ack := handlerEvent(state, chnl, routing.ExchangePerilTopic)(tc.ev); ack != pubsub.Ack {
+				t.Errorf("got %v, want Ack", ack)
+			}
+
+			if tc.wantLog == "" {
+				if got := b.QueueLength("logs"); got != 0 {
+					t.Errorf("%d game logs published, want none", got)
+				}
+				return
+			}
+			deliveries, err := chnl.Consume("logs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case d := <-deliveries:
+				if d.RoutingKey != routing.GameLogKey(tc.username) {
+					t.Errorf("published with key %q, want %q", d.RoutingKey, routing.GameLogKey(tc.username))
+				}
+				var gl routing.GameLog
+				if err := pubsub.Gob.Unmarshal(d.Body, &gl); err != nil {
+					t.Fatal(err)
+				}
+				if gl.Username != tc.username || gl.Message != tc.wantLog {
+					t.Errorf("got log %q from %s, want %q from %s", gl.Message, gl.Username, tc.wantLog, tc.username)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no game log published")
+			}
+		})
+	}
+}
+
+func TestPublishWarLogFailure(t *testing.T) {
+	// the war is applied either way, so a lost log must not requeue it
+	if ack := publishWarLog(failingPublisher{}, routing.ExchangePerilTopic, "alice", "war"); ack != pubsub.Ack {
+		t.Errorf("got %v, want Ack", ack)
+	}
+}
+
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(context.Context, string, string, pubsub.Message) error {
+	return errors.New("broker is down")
+}
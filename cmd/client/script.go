@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// Exit codes of a scripted client.
+const (
+	exitOK           = 0
+	exitExpectFailed = 1
+	exitScriptError  = 2
+	exitInterrupted  = 130
+)
+
+const expectPoll = 50 * time.Millisecond
+
+// errExpect marks a script line whose expectation didn't hold, as opposed
+// to a script that is broken or a command that failed.
+var errExpect = errors.New("expectation failed")
+
+type scriptEnv struct {
+	state     *gamelogic.GameState
+	publisher pubsub.Publisher
+	exchange  string
+	username  string
+	timeout   time.Duration
+}
+
+// runScript runs the client commands in path, or stdin for "-", one per
+// line, and returns the exit code. On top of the usual commands a script
+// can use:
+//
+//	wait <duration>               e.g. wait 2s
+//	wait turn [n]                 until a turn (n or later) is open
+//	expect status <check>...      e.g. expect status units=3 gold>=2 units@europe>0
+//	expect fail <command>         the command must be refused
+//
+// Blank lines and lines starting with # are skipped. Expectations about
+// state are retried until they hold or the timeout runs out, because
+// events from the server arrive in their own time.
+func runScript(ctx context.Context, path string, env *scriptEnv) int {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Printf("can't open script: %v", err)
+			return exitScriptError
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		log.Printf("script:%d: %s", n, line)
+
+		quit, err := env.run(ctx, strings.Fields(line))
+		if errors.Is(err, errExpect) {
+			log.Printf("script:%d: %v", n, err)
+			return exitExpectFailed
+		}
+		if errors.Is(err, context.Canceled) {
+			log.Printf("script:%d: interrupted", n)
+			return exitInterrupted
+		}
+		if err != nil {
+			log.Printf("script:%d: %v", n, err)
+			return exitScriptError
+		}
+		if quit {
+			return exitOK
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("can't read script: %v", err)
+		return exitScriptError
+	}
+	return exitOK
+}
+
+func (env *scriptEnv) run(ctx context.Context, words []string) (bool, error) {
+	switch words[0] {
+	case "wait":
+		return false, env.wait(ctx, words[1:])
+	case "expect":
+		return false, env.expect(ctx, words[1:])
+	}
+	return runCommand(env.state, env.publisher, env.exchange, env.username, words)
+}
+
+func (env *scriptEnv) wait(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: wait <duration> | wait turn [n]")
+	}
+	if args[0] != "turn" {
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return fmt.Errorf("bad wait: %v", err)
+		}
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	want := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("bad turn %q: %v", args[1], err)
+		}
+		want = n
+	}
+	return env.poll(ctx, func() error {
+		turn, open := env.state.CurrentTurn()
+		if !open || turn < want {
+			return fmt.Errorf("%w: waiting for turn %d, at turn %d (open: %v)", errExpect, want, turn, open)
+		}
+		return nil
+	})
+}
+
+func (env *scriptEnv) expect(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: expect status <check>... | expect fail <command>")
+	}
+	switch args[0] {
+	case "status":
+		checks := []check{}
+		for _, arg := range args[1:] {
+			c, err := parseCheck(arg)
+			if err != nil {
+				return err
+			}
+			checks = append(checks, c)
+		}
+		return env.poll(ctx, func() error {
+			for _, c := range checks {
+				if err := c.eval(env.state); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case "fail":
+		_, err := runCommand(env.state, env.publisher, env.exchange, env.username, args[1:])
+		if err == nil {
+			return fmt.Errorf("%w: %s succeeded", errExpect, strings.Join(args[1:], " "))
+		}
+		log.Printf("refused as expected: %v", err)
+		return nil
+	default:
+		return fmt.Errorf("unknown expectation %q", args[0])
+	}
+}
+
+// poll calls f until it succeeds, returning its last error once the
+// timeout runs out.
+func (env *scriptEnv) poll(ctx context.Context, f func() error) error {
+	deadline := time.Now().Add(env.timeout)
+	for {
+		err := f()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-time.After(expectPoll):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// check is one key<op>value term of expect status.
+type check struct {
+	key   string
+	op    string
+	value int
+}
+
+var checkOps = []string{"!=", "<=", ">=", "=", "<", ">"}
+
+func parseCheck(s string) (check, error) {
+	for _, op := range checkOps {
+		key, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		c := check{key: key, op: op}
+		switch value {
+		case "true":
+			c.value = 1
+		case "false":
+			c.value = 0
+		default:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return check{}, fmt.Errorf("bad check %q: %v", s, err)
+			}
+			c.value = n
+		}
+		if _, err := c.lookup(nil); err != nil {
+			return check{}, err
+		}
+		return c, nil
+	}
+	return check{}, fmt.Errorf("bad check %q, want key<op>value with one of %v", s, checkOps)
+}
+
+// lookup finds the value of the check's key. With a nil state it only
+// validates the key.
+func (c check) lookup(gs *gamelogic.GameState) (int, error) {
+	key, loc, byLocation := strings.Cut(c.key, "@")
+	if byLocation && key != "units" {
+		return 0, fmt.Errorf("unknown key %q, only units can be counted by location", c.key)
+	}
+	switch key {
+	case "units", "gold", "turn", "paused", "orders":
+	default:
+		return 0, fmt.Errorf("unknown key %q, want units, units@<location>, gold, turn, paused or orders", c.key)
+	}
+	if gs == nil {
+		return 0, nil
+	}
+
+	switch key {
+	case "units":
+		units := gs.GetPlayerSnap().Units
+		if !byLocation {
+			return len(units), nil
+		}
+		n := 0
+		for _, u := range units {
+			if u.Location == gamelogic.Location(loc) {
+				n++
+			}
+		}
+		return n, nil
+	case "gold":
+		return gs.GetPlayerSnap().Treasury, nil
+	case "turn":
+		turn, _ := gs.CurrentTurn()
+		return turn, nil
+	case "paused":
+		if gs.IsPaused() {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return len(gs.Orders()), nil
+	}
+}
+
+func (c check) eval(gs *gamelogic.GameState) error {
+	got, err := c.lookup(gs)
+	if err != nil {
+		return err
+	}
+	var ok bool
+	switch c.op {
+	case "=":
+		ok = got == c.value
+	case "!=":
+		ok = got != c.value
+	case "<":
+		ok = got < c.value
+	case "<=":
+		ok = got <= c.value
+	case ">":
+		ok = got > c.value
+	case ">=":
+		ok = got >= c.value
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s%s%d, got %d", errExpect, c.key, c.op, c.value, got)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+func TestParseCheck(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    check
+		wantErr bool
+	}{
+		{in: "units=3", want: check{key: "units", op: "=", value: 3}},
+		{in: "gold>=2", want: check{key: "gold", op: ">=", value: 2}},
+		{in: "units@europe>0", want: check{key: "units@europe", op: ">", value: 0}},
+		{in: "turn!=1", want: check{key: "turn", op: "!=", value: 1}},
+		{in: "paused=true", want: check{key: "paused", op: "=", value: 1}},
+		{in: "orders<=0", want: check{key: "orders", op: "<=", value: 0}},
+		{in: "gold", wantErr: true},
+		{in: "gold=lots", wantErr: true},
+		{in: "health=3", wantErr: true},
+		{in: "gold@europe=1", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseCheck(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseCheck(%q) = %+v, want an error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("parseCheck(%q) = %+v, %v, want %+v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestCheckEval(t *testing.T) {
+	gs := testState()
+	tests := []struct {
+		check string
+		ok    bool
+	}{
+		{"units=2", true},
+		{"units@europe=1", true},
+		{"units@asia>0", false},
+		{"gold=7", true},
+		{"gold<7", false},
+		{"turn=3", true},
+		{"paused=false", true},
+		{"orders=0", true},
+	}
+
+	for _, tc := range tests {
+		c, err := parseCheck(tc.check)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.eval(gs)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: got %v, want ok %v", tc.check, err, tc.ok)
+		}
+		if err != nil && !errors.Is(err, errExpect) {
+			t.Errorf("%s: failed check isn't an errExpect: %v", tc.check, err)
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   int
+	}{
+		{"empty", "", exitOK},
+		{"comments and checks", "# setup\n\nexpect status units=2 gold>=7\nwait 1ms\n", exitOK},
+		{"failed expectation", "expect status units=3\n", exitExpectFailed},
+		{"refused command", "expect fail spawn atlantis infantry\n", exitOK},
+		{"bad check", "expect status health=1\n", exitScriptError},
+		{"bad wait", "wait soon\n", exitScriptError},
+		{"quit stops the script", "quit\nexpect status units=3\n", exitOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script")
+			if err := os.WriteFile(path, []byte(tc.script), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			env := &scriptEnv{state: testState(), username: "alice", timeout: 20 * time.Millisecond}
+			if got := runScript(context.Background(), path, env); got != tc.want {
+				t.Errorf("exit code %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunScriptInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(path, []byte("wait 1h\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	env := &scriptEnv{state: testState(), username: "alice", timeout: time.Hour}
+	if got := runScript(ctx, path, env); got != exitInterrupted {
+		t.Errorf("exit code %d, want %d", got, exitInterrupted)
+	}
+}
+
+// testState is alice on turn 3 with two units and 7 gold.
+func testState() *gamelogic.GameState {
+	gs := gamelogic.NewGameState("alice")
+	gs.SetOutput(io.Discard)
+	gs.ApplyEvent(gamelogic.GameEvent{
+		Kind:     gamelogic.EventSync,
+		Username: "alice",
+		Turn:     3,
+		TurnOpen: true,
+		Player: &gamelogic.Player{
+			Username: "alice",
+			Treasury: 7,
+			Units: map[int]gamelogic.Unit{
+				1: {ID: 1, Owner: "alice", Rank: gamelogic.RankInfantry, Location: "europe"},
+				2: {ID: 2, Owner: "alice", Rank: gamelogic.RankCavalry, Location: "africa"},
+			},
+			NextUnitID: 3,
+		},
+	})
+	return gs
+}
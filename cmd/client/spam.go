@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// maxSpamRate is one message a nanosecond, the finest a ticker can go.
+const maxSpamRate = 1e9
+
+type spamResult struct {
+	latency time.Duration
+	err     error
+}
+
+// commandSpam publishes malicious game logs as fast as the flags allow and
+// prints a throughput and latency summary. Usage:
+//
+//	spam <n> [-rate <msgs/sec>] [-duration <time>] [-concurrency <workers>]
+func commandSpam(words []string, publisher pubsub.Publisher, exchange, username string) error {
+	if len(words) < 2 {
+		return errors.New("usage: spam <n> [-rate <msgs/sec>] [-duration <time>] [-concurrency <workers>]")
+	}
+	n, err := strconv.Atoi(words[1])
+	if err != nil || n < 1 {
+		return fmt.Errorf("%s is not a valid number of messages", words[1])
+	}
+
+	fs := flag.NewFlagSet("spam", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	rate := fs.Float64("rate", 0, "messages per second, 0 for unlimited")
+	duration := fs.Duration("duration", 0, "stop after this long even if n hasn't been reached")
+	concurrency := fs.Int("concurrency", 1, "number of concurrent publishers")
+	if err := fs.Parse(words[2:]); err != nil {
+		return err
+	}
+	if *concurrency < 1 {
+		return errors.New("concurrency must be positive")
+	}
+	if *rate < 0 || *duration < 0 {
+		return errors.New("rate and duration can't be negative, use 0 for no limit")
+	}
+	if math.IsNaN(*rate) || *rate > maxSpamRate {
+		return fmt.Errorf("rate must be a number no higher than %g", maxSpamRate)
+	}
+
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		var deadline <-chan time.Time
+		if *duration > 0 {
+			deadline = time.After(*duration)
+		}
+		var tick <-chan time.Time
+		if *rate > 0 {
+			ticker := time.NewTicker(spamInterval(*rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for i := 0; i < n; i++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-deadline:
+					return
+				}
+			}
+			select {
+			case jobs <- struct{}{}:
+			case <-deadline:
+				return
+			}
+		}
+	}()
+
+	results := make(chan spamResult)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				sent := time.Now()
+				err := publishGameLog(publisher, exchange, username, gamelogic.GetMaliciousLog())
+				results <- spamResult{latency: time.Since(sent), err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	latencies := []time.Duration{}
+	failed := 0
+	var firstErr error
+	for r := range results {
+		if r.err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		latencies = append(latencies, r.latency)
+	}
+	elapsed := time.Since(start)
+
+	printSpamSummary(latencies, failed, elapsed)
+	if firstErr != nil {
+		fmt.Printf("first error: %v\n", firstErr)
+	}
+	return nil
+}
+
+// spamInterval is the time between messages at rate, which must be in
+// (0, maxSpamRate]. A rate too low to wait for is a very long wait.
+func spamInterval(rate float64) time.Duration {
+	interval := float64(time.Second) / rate
+	if interval >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(interval)
+}
+
+func printSpamSummary(latencies []time.Duration, failed int, elapsed time.Duration) {
+	fmt.Println("==== Spam Summary ====")
+	fmt.Printf("published %d, failed %d in %v\n", len(latencies), failed, elapsed.Round(time.Millisecond))
+	if len(latencies) == 0 {
+		return
+	}
+
+	slices.Sort(latencies)
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	fmt.Printf("throughput: %.1f msgs/sec\n", float64(len(latencies))/elapsed.Seconds())
+	fmt.Printf("latency: min %v, avg %v, p50 %v, p95 %v, p99 %v, max %v\n",
+		latencies[0],
+		total/time.Duration(len(latencies)),
+		percentile(0.50),
+		percentile(0.95),
+		percentile(0.99),
+		latencies[len(latencies)-1],
+	)
+}
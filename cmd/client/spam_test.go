@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandSpamFlags(t *testing.T) {
+	tests := []struct {
+		words   string
+		wantErr string
+	}{
+		{"spam", "usage"},
+		{"spam 0", "not a valid number"},
+		{"spam 1 -concurrency 0", "concurrency must be positive"},
+		{"spam 1 -rate -1", "can't be negative"},
+		{"spam 1 -duration -1s", "can't be negative"},
+		{"spam 1 -rate NaN", "no higher than"},
+		{"spam 1 -rate +Inf", "no higher than"},
+		{"spam 1 -rate 2e9", "no higher than"},
+		{"spam 1 -rate 1e9", ""},
+		{"spam 1 -rate 1e-300", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.words, func(t *testing.T) {
+			// a tiny rate waits forever for its first tick
+			words := strings.Fields(tc.words)
+			if tc.wantErr == "" {
+				words = append(words, "-duration", "10ms")
+			}
+			err := commandSpam(words, failingPublisher{}, "peril_topic", "alice")
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want one containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpamInterval(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want time.Duration
+	}{
+		{1, time.Second},
+		{4, 250 * time.Millisecond},
+		{maxSpamRate, time.Nanosecond},
+		{1e-300, math.MaxInt64},
+	}
+	for _, tc := range tests {
+		if got := spamInterval(tc.rate); got != tc.want {
+			t.Errorf("spamInterval(%g) = %v, want %v", tc.rate, got, tc.want)
+		}
+	}
+}
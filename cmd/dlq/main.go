@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const (
+	fetchIdleTimeout = 500 * time.Millisecond
+	confirmTimeout   = 5 * time.Second
+)
+
+func usage() {
+	fmt.Println("Usage:")
+	fmt.Println("  dlq list [-n count]")
+	fmt.Println("  dlq replay [-n count] all|<index> <index>...")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	switch os.Args[1] {
+	case "list", "replay":
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	count := fs.Int("n", 50, "maximum number of dead-lettered messages to inspect")
+	cfg := config.MustLoad(fs, os.Args[2:])
+
+	dial, err := cfg.Dialer()
+	if err != nil {
+		log.Fatalf("bad broker config: %v", err)
+	}
+	broker, err := dial()
+	if err != nil {
+		log.Fatalf("amqp connection error: %v", err)
+	}
+	defer broker.Close()
+
+	chnl, err := broker.Channel()
+	if err != nil {
+		log.Fatalf("can't create a new channel: %v", err)
+	}
+	// closing the channel requeues everything we looked at but didn't ack
+	defer chnl.Close()
+
+	if err = pubsub.ApplyTopology(chnl, routing.PerilTopology(cfg.Names())); err != nil {
+		log.Fatalf("can't declare topology: %v", err)
+	}
+
+	msgs, err := fetch(chnl, *count)
+	if err != nil {
+		log.Fatalf("can't read %s: %v", routing.DeadLetterQueue, err)
+	}
+
+	if os.Args[1] == "list" {
+		list(msgs)
+		return
+	}
+	publisher, err := pubsub.NewConfirmingPublisher(chnl, confirmTimeout)
+	if err != nil {
+		log.Fatalf("can't create publisher: %v", err)
+	}
+	if err := replay(publisher, msgs, fs.Args()); err != nil {
+		log.Printf("replay error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// fetch reads up to n messages from the dead letter queue without settling
+// them, stopping early once the queue has been idle for fetchIdleTimeout.
+func fetch(chnl pubsub.Channel, n int) ([]pubsub.Delivery, error) {
+	if err := chnl.Qos(n); err != nil {
+		return nil, err
+	}
+	deliveries, err := chnl.Consume(routing.DeadLetterQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := []pubsub.Delivery{}
+	for len(msgs) < n {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return msgs, nil
+			}
+			msgs = append(msgs, d)
+		case <-time.After(fetchIdleTimeout):
+			return msgs, nil
+		}
+	}
+	return msgs, nil
+}
+
+func list(msgs []pubsub.Delivery) {
+	if len(msgs) == 0 {
+		fmt.Printf("%s is empty\n", routing.DeadLetterQueue)
+		return
+	}
+
+	for i, msg := range msgs {
+		fmt.Printf("[%d] %s, %d bytes\n", i, msg.ContentType, len(msg.Body))
+		for _, d := range pubsub.Deaths(msg.Headers) {
+			fmt.Printf("    %s from queue %s (exchange %q, keys %v) x%d at %s\n",
+				d.Reason, d.Queue, d.Exchange, d.RoutingKeys, d.Count, d.Time.Format(time.RFC3339))
+		}
+		if msg.ContentType == pubsub.ContentTypeJSON {
+			fmt.Printf("    %s\n", msg.Body)
+		}
+	}
+}
+
+// replay republishes the selected messages to where they were first sent.
+// A message is only removed from the dead letter queue once the broker has
+// confirmed that a queue took it; one nobody would receive any more stays
+// where it is.
+func replay(publisher pubsub.Publisher, msgs []pubsub.Delivery, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("nothing to replay; pass 'all' or message indexes")
+	}
+
+	selected := []int{}
+	if args[0] == "all" {
+		for i := range msgs {
+			selected = append(selected, i)
+		}
+	} else {
+		for _, arg := range args {
+			i, err := strconv.Atoi(arg)
+			if err != nil || i < 0 || i >= len(msgs) {
+				return fmt.Errorf("%s is not a valid message index", arg)
+			}
+			selected = append(selected, i)
+		}
+	}
+
+	failed := 0
+	for _, i := range selected {
+		msg := msgs[i]
+		exchange, key, ok := pubsub.OriginalRoute(msg.Headers)
+		if !ok {
+			log.Printf("[%d] has no x-death header, skipping", i)
+			continue
+		}
+
+		// a replayed message starts over with a fresh retry budget
+		delete(msg.Headers, pubsub.HeaderRetryCount)
+		if err := publisher.Publish(context.Background(), exchange, key, msg.Message); err != nil {
+			log.Printf("[%d] couldn't republish, leaving it in %s: %v", i, routing.DeadLetterQueue, err)
+			failed++
+			continue
+		}
+		if err := msg.Ack(); err != nil {
+			return fmt.Errorf("[%d] republished but couldn't ack: %v", i, err)
+		}
+		fmt.Printf("[%d] replayed to %s with key %s\n", i, exchange, key)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d message(s) couldn't be replayed", failed, len(selected))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub/memory"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestReplay(t *testing.T) {
+	b := memory.NewBroker()
+	// a durable queue that is still there, and a client's transient one
+	// that goes away with it
+	deadLetter(t, b.Connect(), "logs", routing.GameLogKey("alice"), pubsub.DurableQueue)
+	client := b.Connect()
+	deadLetter(t, client, routing.EventsQueue("bob"), routing.EventKey("carol"), pubsub.TransientQueue)
+	client.Close()
+
+	chnl, err := b.Connect().Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := fetch(chnl, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("fetched %d dead letters, want 2", len(msgs))
+	}
+	publisher, err := pubsub.NewConfirmingPublisher(chnl, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replay(publisher, msgs, []string{"all"}); err == nil {
+		t.Error("replaying a message nobody can receive succeeded")
+	}
+	chnl.Close()
+
+	if got := b.QueueLength("logs"); got != 1 {
+		t.Errorf("logs has %d messages, want the replayed one", got)
+	}
+	if got := b.QueueLength(routing.DeadLetterQueue); got != 1 {
+		t.Errorf("%s has %d messages, want the unroutable one kept", routing.DeadLetterQueue, got)
+	}
+}
+
+func TestReplayBadIndex(t *testing.T) {
+	for _, args := range [][]string{nil, {"2"}, {"-1"}, {"x"}} {
+		if err := replay(nil, make([]pubsub.Delivery, 2), args); err == nil {
+			t.Errorf("replay %v succeeded", args)
+		}
+	}
+}
+
+// deadLetter publishes a message to queue on conn and rejects it.
+func deadLetter(t *testing.T, conn *memory.Conn, queue, key string, queueType pubsub.SimpleQueueType) {
+	t.Helper()
+	chnl, _, err := pubsub.DeclareAndBind(conn, routing.ExchangePerilTopic, queue, key, queueType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.PublishJSON(chnl, routing.ExchangePerilTopic, key, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := chnl.Consume(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-deliveries:
+		if err := d.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("nothing delivered from %s", queue)
+	}
+	chnl.Close()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gameserver"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func handlerLogs(path string) func(routing.GameLog) pubsub.AckType {
+	return func(gamelog routing.GameLog) pubsub.AckType {
+		defer fmt.Print("> ")
+		if err := gamelogic.WriteLog(path, gamelog); err != nil {
+			log.Printf("error writing log: %v", err)
+			return pubsub.NackRequeue
+		}
+		return pubsub.Ack
+	}
+}
+
+func handlerIntent(world *gamelogic.World, publisher pubsub.Publisher, names routing.Names) func(string, gamelogic.Intent) pubsub.AckType {
+	handle := gameserver.HandleIntent(world, publisher, names)
+	return func(key string, intent gamelogic.Intent) pubsub.AckType {
+		defer fmt.Print("> ")
+		return handle(key, intent)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+)
+
+// setting is a config value that can be set from the environment and,
+// unless flag is empty, the command line as well as the file.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	field func(*Config) *string
+}
+
+var settings = []setting{
+	{"url", "PERIL_URL", "RabbitMQ URL", func(c *Config) *string { return &c.Broker.URL }},
+	{"vhost", "PERIL_VHOST", "RabbitMQ virtual host, overriding the one in the URL", func(c *Config) *string { return &c.Broker.VHost }},
+	{"username", "PERIL_USERNAME", "RabbitMQ username, overriding the one in the URL", func(c *Config) *string { return &c.Broker.Username }},
+	{"", "PERIL_PASSWORD", "", func(c *Config) *string { return &c.Broker.Password }},
+	{"password-file", "PERIL_PASSWORD_FILE", "file holding the RabbitMQ password", func(c *Config) *string { return &c.Broker.PasswordFile }},
+	{"auth", "PERIL_AUTH", "RabbitMQ login: plain or external (TLS client certificate)", func(c *Config) *string { return &c.Broker.Auth }},
+	{"tls-ca", "PERIL_TLS_CA", "CA certificates to trust for amqps, instead of the system's", func(c *Config) *string { return &c.Broker.TLS.CA }},
+	{"tls-cert", "PERIL_TLS_CERT", "client certificate for amqps", func(c *Config) *string { return &c.Broker.TLS.Cert }},
+	{"tls-key", "PERIL_TLS_KEY", "client certificate key for amqps", func(c *Config) *string { return &c.Broker.TLS.Key }},
+	{"tls-server-name", "PERIL_TLS_SERVER_NAME", "name the broker's certificate must have, if not the URL's host", func(c *Config) *string { return &c.Broker.TLS.ServerName }},
+	{"board", "PERIL_BOARD", "JSON board to play on instead of the classic map, must match the server's", func(c *Config) *string { return &c.Board }},
+	{"log", "PERIL_LOG", "file the server writes game logs to", func(c *Config) *string { return &c.LogPath }},
+	{"exchange-direct", "PERIL_EXCHANGE_DIRECT", "name of the direct exchange", func(c *Config) *string { return &c.Exchanges.Direct }},
+	{"exchange-topic", "PERIL_EXCHANGE_TOPIC", "name of the topic exchange", func(c *Config) *string { return &c.Exchanges.Topic }},
+	{"exchange-dlx", "PERIL_EXCHANGE_DLX", "name of the dead letter exchange", func(c *Config) *string { return &c.Exchanges.DeadLetter }},
+}
+
+// Load registers the config flags on fs, parses args with it and builds
+// the config from the defaults, the file named by -config or PERIL_CONFIG,
+// the environment and the flags, in that order. The caller registers its
+// own flags on fs first.
+func Load(fs *flag.FlagSet, args []string) (Config, bool, error) {
+	path := fs.String("config", "", "JSON config file, or $PERIL_CONFIG")
+	printConfig := fs.Bool("print-config", false, "print the effective config and exit")
+	values := make([]*string, len(settings))
+	for i, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		values[i] = fs.String(s.flag, "", fmt.Sprintf("%s, or $%s", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	cfg := Default()
+	if *path == "" {
+		*path = os.Getenv("PERIL_CONFIG")
+	}
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return Config{}, false, err
+		}
+	}
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			*s.field(&cfg) = v
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for i, s := range settings {
+			if s.flag != "" && s.flag == f.Name {
+				*s.field(&cfg) = *values[i]
+			}
+		}
+	})
+
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return Config{}, false, err
+	}
+	return cfg, *printConfig, nil
+}
+
+// MustLoad is Load for a main: it exits on a bad config, and prints the
+// config and exits if asked to.
+func MustLoad(fs *flag.FlagSet, args []string) Config {
+	cfg, printConfig, err := Load(fs, args)
+	if err != nil {
+		log.Fatalf("bad config: %v", err)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("can't print config: %v", err)
+		}
+		os.Exit(0)
+	}
+	return cfg
+}
+
+// Print writes the config as JSON in the format loadFile reads, with
+// passwords masked.
+func (c Config) Print(w io.Writer) error {
+	if u, err := url.Parse(c.Broker.URL); err == nil {
+		c.Broker.URL = u.Redacted()
+	}
+	if c.Broker.Password != "" {
+		c.Broker.Password = "xxxxx"
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// loadFile overrides c with whatever the file sets. Unknown keys are an
+// error so that typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("can't read config: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("bad config %s: %v", path, err)
+	}
+	return nil
+}
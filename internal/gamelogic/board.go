@@ -0,0 +1,296 @@
+package gamelogic
+
+import (
+	"container/heap"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+//go:embed boards/classic.json
+var classicBoard []byte
+
+var (
+	ErrUnknownLocation = errors.New("not a valid location")
+	ErrNoPath          = errors.New("no path between locations")
+	ErrAlreadyThere    = errors.New("unit is already there")
+)
+
+// defaultMovement is how many movement points each rank gets per turn when
+// a board doesn't say otherwise. Crossing an edge costs the edge's cost, and
+// points left over carry into the next turn while the unit is on the road.
+var defaultMovement = map[UnitRank]int{
+	RankInfantry:  2,
+	RankCavalry:   4,
+	RankArtillery: 1,
+}
+
+// defaultCosts is what each rank costs to spawn when a board doesn't say
+// otherwise.
+var defaultCosts = map[UnitRank]int{
+	RankInfantry:  1,
+	RankCavalry:   3,
+	RankArtillery: 5,
+}
+
+// defaultIncome is what a location yields its controller each turn when a
+// board doesn't say otherwise.
+const defaultIncome = 1
+
+// Board is the map the game is played on: the locations, the cost of moving
+// between neighbouring ones, the economy and how each rank fights.
+type Board struct {
+	Name      string
+	locations []Location
+	adjacent  map[Location]map[Location]int
+	movement  map[UnitRank]int
+	costs     map[UnitRank]int
+	income    map[Location]int
+	stats     map[UnitRank]RankStats
+}
+
+type boardFile struct {
+	Name      string           `json:"name"`
+	Locations []Location       `json:"locations"`
+	Edges     []boardEdge      `json:"edges"`
+	Movement  map[UnitRank]int `json:"movement"`
+	Costs     map[UnitRank]int `json:"costs"`
+	Income    map[Location]int `json:"income"`
+}
+
+type boardEdge struct {
+	From Location `json:"from"`
+	To   Location `json:"to"`
+	Cost int      `json:"cost"`
+}
+
+// DefaultBoard is the classic six continent map shipped with the game.
+func DefaultBoard() *Board {
+	b, err := ParseBoard(classicBoard)
+	if err != nil {
+		panic(fmt.Sprintf("embedded board is invalid: %v", err))
+	}
+	return b
+}
+
+// LoadBoard reads a board from a JSON file.
+func LoadBoard(path string) (*Board, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open board: %v", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read board: %v", err)
+	}
+	return ParseBoard(data)
+}
+
+// ParseBoard decodes and validates a JSON board. Edges are two-way.
+func ParseBoard(data []byte) (*Board, error) {
+	var bf boardFile
+	if err := json.Unmarshal(data, &bf); err != nil {
+		return nil, fmt.Errorf("could not decode board: %v", err)
+	}
+	if len(bf.Locations) == 0 {
+		return nil, errors.New("board has no locations")
+	}
+
+	b := &Board{
+		Name:     bf.Name,
+		adjacent: map[Location]map[Location]int{},
+		movement: map[UnitRank]int{},
+		costs:    map[UnitRank]int{},
+		income:   map[Location]int{},
+		stats:    DefaultRankStats(),
+	}
+	for _, loc := range bf.Locations {
+		if _, ok := b.adjacent[loc]; ok {
+			return nil, fmt.Errorf("location %s is listed twice", loc)
+		}
+		b.adjacent[loc] = map[Location]int{}
+		b.income[loc] = defaultIncome
+		b.locations = append(b.locations, loc)
+	}
+	for _, e := range bf.Edges {
+		if !b.HasLocation(e.From) || !b.HasLocation(e.To) {
+			return nil, fmt.Errorf("edge %s-%s: %w", e.From, e.To, ErrUnknownLocation)
+		}
+		if e.From == e.To || e.Cost < 1 {
+			return nil, fmt.Errorf("edge %s-%s must join two locations with a positive cost", e.From, e.To)
+		}
+		b.adjacent[e.From][e.To] = e.Cost
+		b.adjacent[e.To][e.From] = e.Cost
+	}
+	for rank, points := range defaultMovement {
+		b.movement[rank] = points
+	}
+	for rank, points := range bf.Movement {
+		if _, ok := getAllRanks()[rank]; !ok {
+			return nil, fmt.Errorf("movement for unknown rank %s", rank)
+		}
+		if points < 1 {
+			return nil, fmt.Errorf("%s must have positive movement", rank)
+		}
+		b.movement[rank] = points
+	}
+	for rank, cost := range defaultCosts {
+		b.costs[rank] = cost
+	}
+	for rank, cost := range bf.Costs {
+		if _, ok := getAllRanks()[rank]; !ok {
+			return nil, fmt.Errorf("cost for unknown rank %s", rank)
+		}
+		if cost < 0 {
+			return nil, fmt.Errorf("%s can't have a negative cost", rank)
+		}
+		b.costs[rank] = cost
+	}
+	for loc, income := range bf.Income {
+		if !b.HasLocation(loc) {
+			return nil, fmt.Errorf("income for %s: %w", loc, ErrUnknownLocation)
+		}
+		if income < 0 {
+			return nil, fmt.Errorf("%s can't have a negative income", loc)
+		}
+		b.income[loc] = income
+	}
+	return b, nil
+}
+
+func (b *Board) Locations() []Location {
+	return slices.Clone(b.locations)
+}
+
+func (b *Board) HasLocation(loc Location) bool {
+	_, ok := b.adjacent[loc]
+	return ok
+}
+
+// Neighbours returns the locations one edge away from loc.
+func (b *Board) Neighbours(loc Location) []Location {
+	neighbours := []Location{}
+	for _, l := range b.locations {
+		if _, ok := b.adjacent[loc][l]; ok {
+			neighbours = append(neighbours, l)
+		}
+	}
+	return neighbours
+}
+
+// Movement is how many movement points a unit of rank gets each turn.
+func (b *Board) Movement(rank UnitRank) int {
+	return b.movement[rank]
+}
+
+// Cost is what a unit of rank costs to spawn.
+func (b *Board) Cost(rank UnitRank) int {
+	return b.costs[rank]
+}
+
+// Income is what loc yields its controller at the end of each turn.
+func (b *Board) Income(loc Location) int {
+	return b.income[loc]
+}
+
+// Path returns the cheapest route from one location to another, excluding
+// from itself.
+func (b *Board) Path(from, to Location) ([]Location, error) {
+	if !b.HasLocation(from) {
+		return nil, fmt.Errorf("%s is %w", from, ErrUnknownLocation)
+	}
+	if !b.HasLocation(to) {
+		return nil, fmt.Errorf("%s is %w", to, ErrUnknownLocation)
+	}
+	if from == to {
+		return nil, fmt.Errorf("%s: %w", to, ErrAlreadyThere)
+	}
+
+	dist := map[Location]int{from: 0}
+	prev := map[Location]Location{}
+	queue := &pathQueue{{loc: from}}
+	for queue.Len() > 0 {
+		cur := heap.Pop(queue).(pathStep)
+		if cur.loc == to {
+			break
+		}
+		if cur.cost > dist[cur.loc] {
+			continue
+		}
+		// walk neighbours in board order so ties always pick the same path
+		for _, next := range b.Neighbours(cur.loc) {
+			cost := cur.cost + b.adjacent[cur.loc][next]
+			if d, ok := dist[next]; ok && d <= cost {
+				continue
+			}
+			dist[next] = cost
+			prev[next] = cur.loc
+			heap.Push(queue, pathStep{loc: next, cost: cost})
+		}
+	}
+	if _, ok := dist[to]; !ok {
+		return nil, fmt.Errorf("%s to %s: %w", from, to, ErrNoPath)
+	}
+
+	path := []Location{}
+	for loc := to; loc != from; loc = prev[loc] {
+		path = append(path, loc)
+	}
+	slices.Reverse(path)
+	return path, nil
+}
+
+// March moves u as far along its path as one turn of movement allows.
+func (b *Board) March(u Unit) Unit {
+	points := u.Progress + b.Movement(u.Rank)
+	for len(u.Path) > 0 {
+		cost := b.adjacent[u.Location][u.Path[0]]
+		if points < cost {
+			break
+		}
+		points -= cost
+		u.Location = u.Path[0]
+		u.Path = u.Path[1:]
+	}
+	u.Progress = 0
+	if len(u.Path) > 0 {
+		u.Progress = points
+	} else {
+		u.Path = nil
+	}
+	return u
+}
+
+// TurnsToArrive is how many turns of marching u needs to reach the end of
+// its path.
+func (b *Board) TurnsToArrive(u Unit) int {
+	turns := 0
+	for len(u.Path) > 0 {
+		u = b.March(u)
+		turns++
+	}
+	return turns
+}
+
+type pathStep struct {
+	loc  Location
+	cost int
+}
+
+type pathQueue []pathStep
+
+func (q pathQueue) Len() int           { return len(q) }
+func (q pathQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q pathQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x any)        { *q = append(*q, x.(pathStep)) }
+func (q *pathQueue) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,159 @@
+package gamelogic
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testBoard is a line a-b-c with a slow shortcut from a to c, and d off on
+// its own.
+func testBoard(t *testing.T) *Board {
+	t.Helper()
+	b, err := ParseBoard([]byte(`{
+		"name": "test",
+		"locations": ["a", "b", "c", "d"],
+		"edges": [
+			{"from": "a", "to": "b", "cost": 1},
+			{"from": "b", "to": "c", "cost": 1},
+			{"from": "a", "to": "c", "cost": 5}
+		],
+		"income": {"c": 4}
+	}`))
+	if err != nil {
+		t.Fatalf("test board: %v", err)
+	}
+	return b
+}
+
+func TestBoardPath(t *testing.T) {
+	b := testBoard(t)
+	tests := []struct {
+		from, to Location
+		want     []Location
+		wantErr  error
+	}{
+		{"a", "b", []Location{"b"}, nil},
+		{"a", "c", []Location{"b", "c"}, nil},
+		{"c", "a", []Location{"b", "a"}, nil},
+		{"a", "a", nil, ErrAlreadyThere},
+		{"a", "d", nil, ErrNoPath},
+		{"a", "x", nil, ErrUnknownLocation},
+		{"x", "a", nil, ErrUnknownLocation},
+	}
+
+	for _, tc := range tests {
+		got, err := b.Path(tc.from, tc.to)
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Path(%s, %s) error = %v, want %v", tc.from, tc.to, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Path(%s, %s): %v", tc.from, tc.to, err)
+			continue
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Path(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestClassicBoardPaths(t *testing.T) {
+	b := DefaultBoard()
+	for _, from := range b.Locations() {
+		for _, to := range b.Locations() {
+			if from == to {
+				continue
+			}
+			path, err := b.Path(from, to)
+			if err != nil {
+				t.Errorf("no path from %s to %s: %v", from, to, err)
+				continue
+			}
+			if path[len(path)-1] != to {
+				t.Errorf("path from %s to %s ends at %s", from, to, path[len(path)-1])
+			}
+		}
+	}
+}
+
+func TestBoardMarch(t *testing.T) {
+	b := testBoard(t)
+	tests := []struct {
+		name      string
+		unit      Unit
+		wantLoc   Location
+		wantPath  []Location
+		wantTurns int
+	}{
+		{
+			name:      "infantry covers two cheap edges",
+			unit:      Unit{Rank: RankInfantry, Location: "a", Path: []Location{"b", "c"}},
+			wantLoc:   "c",
+			wantTurns: 1,
+		},
+		{
+			name:      "artillery takes an edge a turn",
+			unit:      Unit{Rank: RankArtillery, Location: "a", Path: []Location{"b", "c"}},
+			wantLoc:   "b",
+			wantPath:  []Location{"c"},
+			wantTurns: 2,
+		},
+		{
+			name:      "progress carries over on a long edge",
+			unit:      Unit{Rank: RankInfantry, Location: "a", Path: []Location{"c"}},
+			wantLoc:   "a",
+			wantPath:  []Location{"c"},
+			wantTurns: 3,
+		},
+		{
+			name:    "a unit without a path stays put",
+			unit:    Unit{Rank: RankCavalry, Location: "d"},
+			wantLoc: "d",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := b.March(tc.unit)
+			if got.Location != tc.wantLoc || !slices.Equal(got.Path, tc.wantPath) {
+				t.Errorf("got %s with path %v, want %s with path %v", got.Location, got.Path, tc.wantLoc, tc.wantPath)
+			}
+			if turns := b.TurnsToArrive(tc.unit); turns != tc.wantTurns {
+				t.Errorf("TurnsToArrive = %d, want %d", turns, tc.wantTurns)
+			}
+		})
+	}
+}
+
+func TestParseBoardErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"not json", `{`, "could not decode board"},
+		{"no locations", `{"locations": []}`, "no locations"},
+		{"duplicate location", `{"locations": ["a", "a"]}`, "listed twice"},
+		{"edge to nowhere", `{"locations": ["a"], "edges": [{"from": "a", "to": "b", "cost": 1}]}`, "not a valid location"},
+		{"free edge", `{"locations": ["a", "b"], "edges": [{"from": "a", "to": "b", "cost": 0}]}`, "positive cost"},
+		{"loop", `{"locations": ["a"], "edges": [{"from": "a", "to": "a", "cost": 1}]}`, "positive cost"},
+		{"unknown rank movement", `{"locations": ["a"], "movement": {"dragon": 3}}`, "unknown rank"},
+		{"no movement", `{"locations": ["a"], "movement": {"infantry": 0}}`, "positive movement"},
+		{"negative cost", `{"locations": ["a"], "costs": {"infantry": -1}}`, "negative cost"},
+		{"income elsewhere", `{"locations": ["a"], "income": {"b": 1}}`, "not a valid location"},
+		{"negative income", `{"locations": ["a"], "income": {"a": -1}}`, "negative income"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseBoard([]byte(tc.json))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v, want one containing %q", err, tc.want)
+			}
+		})
+	}
+}
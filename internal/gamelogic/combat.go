@@ -0,0 +1,159 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+// maxBattleRounds is how many rounds a battle lasts if neither side is
+// wiped out. Survivors stay put and fight again next turn.
+const maxBattleRounds = 3
+
+// Dice rolls a six-sided die. A nil Dice always rolls 3, so combat without
+// dice is fully predictable.
+type Dice interface {
+	Roll() int
+}
+
+type seededDice struct {
+	r *rand.Rand
+}
+
+// NewDice returns dice that roll the same sequence for the same seed, so a
+// game can be replayed exactly.
+func NewDice(seed int64) Dice {
+	return &seededDice{r: rand.New(rand.NewSource(seed))}
+}
+
+func (d *seededDice) Roll() int {
+	return d.r.Intn(6) + 1
+}
+
+func roll(dice Dice) int {
+	if dice == nil {
+		return 3
+	}
+	return dice.Roll()
+}
+
+// RankStats is how a rank fights: Power is how hard a healthy unit hits
+// and Health how much damage it can take before it dies.
+type RankStats struct {
+	Power  int `json:"power"`
+	Health int `json:"health"`
+}
+
+// DefaultRankStats returns the stats units have unless the board's
+// SetRankStats says otherwise.
+func DefaultRankStats() map[UnitRank]RankStats {
+	return map[UnitRank]RankStats{
+		RankInfantry:  {Power: 1, Health: 5},
+		RankCavalry:   {Power: 5, Health: 10},
+		RankArtillery: {Power: 10, Health: 10},
+	}
+}
+
+// SetRankStats changes how every rank fights on b. Ranks missing from stats
+// keep their current stats. It must be called before the game starts, and
+// the server and all clients must agree, or clients will misjudge battles.
+func (b *Board) SetRankStats(stats map[UnitRank]RankStats) error {
+	ranks := getAllRanks()
+	listed := make([]UnitRank, 0, len(stats))
+	for rank := range stats {
+		listed = append(listed, rank)
+	}
+	slices.Sort(listed)
+
+	errs := []error{}
+	for _, rank := range listed {
+		s := stats[rank]
+		if _, ok := ranks[rank]; !ok {
+			errs = append(errs, fmt.Errorf("unknown rank %q", rank))
+		} else if s.Power <= 0 || s.Health <= 0 {
+			errs = append(errs, fmt.Errorf("%s needs a positive power and health, got %d and %d", rank, s.Power, s.Health))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	for rank, s := range stats {
+		b.stats[rank] = s
+	}
+	return nil
+}
+
+// MaxHealth is how much damage a unit of rank can take before it dies.
+func (b *Board) MaxHealth(rank UnitRank) int {
+	if s, ok := b.stats[rank]; ok {
+		return s.Health
+	}
+	return 1
+}
+
+// RankPower is how hard a healthy unit of rank hits.
+func (b *Board) RankPower(rank UnitRank) int {
+	return b.stats[rank].Power
+}
+
+// healthy returns u with its health clamped to its rank's range, treating
+// a missing health as full.
+func (b *Board) healthy(u Unit) Unit {
+	if u.Health <= 0 || u.Health > b.MaxHealth(u.Rank) {
+		u.Health = b.MaxHealth(u.Rank)
+	}
+	return u
+}
+
+// unitPower scales a unit's rank power by how healthy it is. A living unit
+// always has some fight left in it.
+func (b *Board) unitPower(u Unit) int {
+	full := b.MaxHealth(u.Rank)
+	return (b.RankPower(u.Rank)*u.Health + full - 1) / full
+}
+
+// BattleRound is the damage each side dealt in one round of a battle.
+type BattleRound struct {
+	AttackerDamage int
+	DefenderDamage int
+}
+
+// fight runs a battle between two armies in one location. Each round both
+// sides deal damage proportional to their power at the start of the round,
+// and it is spread over the enemy in ID order so the oldest units fall
+// first.
+func fight(board *Board, battle *BattleResult, attackers, defenders []Unit, dice Dice) {
+	for range maxBattleRounds {
+		if len(attackers) == 0 || len(defenders) == 0 {
+			break
+		}
+		round := BattleRound{
+			AttackerDamage: board.PowerLevel(attackers) * roll(dice) / 2,
+			DefenderDamage: board.PowerLevel(defenders) * roll(dice) / 2,
+		}
+		var killed []UnitRef
+		defenders, killed = takeDamage(defenders, round.AttackerDamage)
+		battle.DefenderLosses = append(battle.DefenderLosses, killed...)
+		attackers, killed = takeDamage(attackers, round.DefenderDamage)
+		battle.AttackerLosses = append(battle.AttackerLosses, killed...)
+		battle.Rounds = append(battle.Rounds, round)
+	}
+	battle.AttackerSurvivors = attackers
+	battle.DefenderSurvivors = defenders
+}
+
+func takeDamage(units []Unit, damage int) (survivors []Unit, killed []UnitRef) {
+	survivors = []Unit{}
+	for _, u := range units {
+		hit := min(damage, u.Health)
+		damage -= hit
+		u.Health -= hit
+		if u.Health == 0 {
+			killed = append(killed, u.Ref())
+			continue
+		}
+		survivors = append(survivors, u)
+	}
+	return survivors, killed
+}
@@ -0,0 +1,171 @@
+package gamelogic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveWar(t *testing.T) {
+	b := DefaultBoard()
+	army := func(owner string, loc Location, ranks ...UnitRank) Player {
+		p := Player{Username: owner, Units: map[int]Unit{}}
+		for _, rank := range ranks {
+			id := p.AllocateUnitID()
+			p.Units[id] = Unit{ID: id, Owner: owner, Rank: rank, Location: loc}
+		}
+		return p
+	}
+
+	tests := []struct {
+		name           string
+		attacker       Player
+		defender       Player
+		wantWar        bool
+		wantWinner     string
+		wantDraw       bool
+		wantCasualties []UnitRef
+	}{
+		{
+			name:     "no shared location",
+			attacker: army("alice", "europe", RankInfantry),
+			defender: army("bob", "asia", RankInfantry),
+		},
+		{
+			name:           "artillery crushes infantry",
+			attacker:       army("alice", "europe", RankArtillery),
+			defender:       army("bob", "europe", RankInfantry),
+			wantWar:        true,
+			wantWinner:     "alice",
+			wantCasualties: []UnitRef{{Owner: "bob", ID: 1}},
+		},
+		{
+			name:           "defender can win",
+			attacker:       army("alice", "asia", RankInfantry),
+			defender:       army("bob", "asia", RankCavalry),
+			wantWar:        true,
+			wantWinner:     "bob",
+			wantCasualties: []UnitRef{{Owner: "alice", ID: 1}},
+		},
+		{
+			name:           "even armies wound each other to a draw",
+			attacker:       army("alice", "africa", RankInfantry, RankInfantry),
+			defender:       army("bob", "africa", RankInfantry, RankInfantry),
+			wantWar:        true,
+			wantDraw:       true,
+			wantCasualties: []UnitRef{{Owner: "alice", ID: 1}, {Owner: "bob", ID: 1}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, ok := resolveWar(b, tc.attacker, tc.defender, nil)
+			if ok != tc.wantWar {
+				t.Fatalf("war = %v, want %v", ok, tc.wantWar)
+			}
+			if !ok {
+				return
+			}
+			winner, _, draw := result.Outcome()
+			if winner != tc.wantWinner || draw != tc.wantDraw {
+				t.Errorf("outcome = %q (draw %v), want %q (draw %v)", winner, draw, tc.wantWinner, tc.wantDraw)
+			}
+			if got := result.Casualties(); !sameRefs(got, tc.wantCasualties) {
+				t.Errorf("casualties = %v, want %v", got, tc.wantCasualties)
+			}
+		})
+	}
+}
+
+func TestResolveWarBattleOrder(t *testing.T) {
+	alice := Player{Username: "alice", Units: map[int]Unit{
+		1: {ID: 1, Owner: "alice", Rank: RankArtillery, Location: "europe"},
+		2: {ID: 2, Owner: "alice", Rank: RankInfantry, Location: "asia"},
+	}}
+	bob := Player{Username: "bob", Units: map[int]Unit{
+		1: {ID: 1, Owner: "bob", Rank: RankInfantry, Location: "europe"},
+		2: {ID: 2, Owner: "bob", Rank: RankCavalry, Location: "asia"},
+		3: {ID: 3, Owner: "bob", Rank: RankInfantry, Location: "africa"},
+	}}
+
+	result, ok := resolveWar(DefaultBoard(), alice, bob, nil)
+	if !ok {
+		t.Fatal("no war between players sharing two locations")
+	}
+	var got []Location
+	for _, battle := range result.Battles {
+		got = append(got, battle.Location)
+	}
+	if want := []Location{"asia", "europe"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("battles fought in %v, want %v", got, want)
+	}
+	if result.Battles[0].Winner != "bob" || result.Battles[1].Winner != "alice" {
+		t.Errorf("asia won by %q and europe by %q, want bob and alice", result.Battles[0].Winner, result.Battles[1].Winner)
+	}
+	if _, _, draw := result.Outcome(); !draw {
+		t.Error("a war split one battle each isn't a draw")
+	}
+	wantCasualties := []UnitRef{{Owner: "alice", ID: 2}, {Owner: "bob", ID: 1}}
+	if got := result.Casualties(); !sameRefs(got, wantCasualties) {
+		t.Errorf("casualties = %v, want %v", got, wantCasualties)
+	}
+}
+
+func TestPowerLevel(t *testing.T) {
+	b := DefaultBoard()
+	tests := []struct {
+		name  string
+		units []Unit
+		want  int
+	}{
+		{"nobody", nil, 0},
+		{"healthy army", []Unit{{Rank: RankInfantry, Health: 5}, {Rank: RankCavalry, Health: 10}, {Rank: RankArtillery, Health: 10}}, 16},
+		{"wounded cavalry", []Unit{{Rank: RankCavalry, Health: 5}}, 3},
+		{"barely alive infantry still fights", []Unit{{Rank: RankInfantry, Health: 1}}, 1},
+	}
+
+	for _, tc := range tests {
+		if got := b.PowerLevel(tc.units); got != tc.want {
+			t.Errorf("%s: PowerLevel = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSetRankStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		stats   map[UnitRank]RankStats
+		wantErr bool
+	}{
+		{"override one rank", map[UnitRank]RankStats{RankInfantry: {Power: 2, Health: 8}}, false},
+		{"unknown rank", map[UnitRank]RankStats{"dragon": {Power: 2, Health: 8}}, true},
+		{"no health", map[UnitRank]RankStats{RankCavalry: {Power: 2}}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := DefaultBoard()
+			err := b.SetRankStats(tc.stats)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tc.wantErr)
+			}
+			want := DefaultRankStats()
+			if !tc.wantErr {
+				for rank, s := range tc.stats {
+					want[rank] = s
+				}
+			}
+			for rank, s := range want {
+				if b.RankPower(rank) != s.Power || b.MaxHealth(rank) != s.Health {
+					t.Errorf("%s has %d power and %d health, want %+v", rank, b.RankPower(rank), b.MaxHealth(rank), s)
+				}
+			}
+		})
+	}
+}
+
+func sameRefs(a, b []UnitRef) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
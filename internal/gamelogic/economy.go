@@ -0,0 +1,64 @@
+package gamelogic
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// startingTreasury is what a new player has to build their first army.
+	startingTreasury = 10
+	// baseIncome is paid to every player each turn, so a player who has
+	// lost everything can claw their way back.
+	baseIncome = 1
+)
+
+// Controllers maps each location to the player controlling it: the only
+// player with units there. Empty and contested locations are missing.
+func Controllers(players []Player) map[Location]string {
+	occupants := map[Location][]string{}
+	for _, p := range players {
+		for _, unit := range p.Units {
+			if !slices.Contains(occupants[unit.Location], p.Username) {
+				occupants[unit.Location] = append(occupants[unit.Location], p.Username)
+			}
+		}
+	}
+	controllers := map[Location]string{}
+	for loc, names := range occupants {
+		if len(names) == 1 {
+			controllers[loc] = names[0]
+		}
+	}
+	return controllers
+}
+
+// CanSpawnAt reports whether username may spawn in loc. Players spawn in
+// locations they control; a player who controls nothing may land anywhere
+// nobody else controls.
+func CanSpawnAt(controllers map[Location]string, username string, loc Location) error {
+	owner, owned := controllers[loc]
+	if owner == username {
+		return nil
+	}
+	for _, name := range controllers {
+		if name == username {
+			return fmt.Errorf("you don't control %s", loc)
+		}
+	}
+	if owned {
+		return fmt.Errorf("%s is controlled by %s", loc, owner)
+	}
+	return nil
+}
+
+// turnIncome is what username earns at the end of a turn.
+func (b *Board) turnIncome(controllers map[Location]string, username string) int {
+	total := baseIncome
+	for _, loc := range b.locations {
+		if controllers[loc] == username {
+			total += b.Income(loc)
+		}
+	}
+	return total
+}
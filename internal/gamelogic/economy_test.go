@@ -0,0 +1,68 @@
+package gamelogic
+
+import "testing"
+
+func TestControllers(t *testing.T) {
+	players := []Player{
+		{Username: "alice", Units: map[int]Unit{
+			1: {Location: "europe"},
+			2: {Location: "europe"},
+			3: {Location: "asia"},
+		}},
+		{Username: "bob", Units: map[int]Unit{
+			1: {Location: "asia"},
+			2: {Location: "africa"},
+		}},
+	}
+	got := Controllers(players)
+	want := map[Location]string{"europe": "alice", "africa": "bob"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for loc, name := range want {
+		if got[loc] != name {
+			t.Errorf("%s is controlled by %q, want %q", loc, got[loc], name)
+		}
+	}
+}
+
+func TestCanSpawnAt(t *testing.T) {
+	controllers := map[Location]string{"europe": "alice", "africa": "bob"}
+	tests := []struct {
+		username string
+		loc      Location
+		ok       bool
+	}{
+		{"alice", "europe", true},
+		{"alice", "asia", false},
+		{"alice", "africa", false},
+		{"carol", "asia", true},
+		{"carol", "europe", false},
+	}
+
+	for _, tc := range tests {
+		err := CanSpawnAt(controllers, tc.username, tc.loc)
+		if (err == nil) != tc.ok {
+			t.Errorf("CanSpawnAt(%s, %s) = %v, want ok %v", tc.username, tc.loc, err, tc.ok)
+		}
+	}
+}
+
+func TestTurnIncome(t *testing.T) {
+	b := DefaultBoard()
+	controllers := map[Location]string{"europe": "alice", "asia": "alice", "africa": "bob"}
+	tests := []struct {
+		username string
+		want     int
+	}{
+		{"alice", baseIncome + 3 + 4},
+		{"bob", baseIncome + 2},
+		{"carol", baseIncome},
+	}
+
+	for _, tc := range tests {
+		if got := b.turnIncome(controllers, tc.username); got != tc.want {
+			t.Errorf("%s earns %d, want %d", tc.username, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package gamelogic
+
+import (
+	"fmt"
+)
+
+// ApplyEvent updates the local game state with a change the server has
+// already made to the world.
+func (gs *GameState) ApplyEvent(ev GameEvent) {
+	defer fmt.Fprintln(gs.out, "------------------------")
+	fmt.Fprintln(gs.out)
+	mine := ev.Username == gs.GetUsername()
+
+	switch ev.Kind {
+	case EventSync:
+		if !mine || ev.Player == nil {
+			return
+		}
+		gs.syncPlayer(*ev.Player)
+		gs.setTurn(ev.Turn, ev.TurnOpen)
+		if ev.Paused {
+			gs.pauseGame()
+		} else {
+			gs.resumeGame()
+		}
+		fmt.Fprintf(gs.out, "==== Synced ====\nYou have %d units and %d gold.\n", len(ev.Player.Units), ev.Player.Treasury)
+	case EventSpawned:
+		if !mine {
+			return
+		}
+		gs.setTreasury(ev.Treasury)
+		for _, unit := range ev.Units {
+			gs.addUnit(unit)
+			fmt.Fprintf(gs.out, "Spawned a(n) %s in %s as %v\n", unit.Rank, unit.Location, unit.Ref())
+		}
+	case EventMoved:
+		if mine {
+			for _, unit := range ev.Units {
+				gs.UpdateUnit(unit)
+				fmt.Fprintf(gs.out, "Unit %v is in %s%s\n", unit.Ref(), unit.Location, describeMarch(unit))
+			}
+			return
+		}
+		fmt.Fprintln(gs.out, "==== Move Detected ====")
+		fmt.Fprintf(gs.out, "%s is moving %v unit(s)\n", ev.Username, len(ev.Units))
+		for _, unit := range ev.Units {
+			fmt.Fprintf(gs.out, "* %v %v in %s%s\n", unit.Ref(), unit.Rank, unit.Location, describeMarch(unit))
+		}
+	case EventWar:
+		gs.applyWar(ev)
+	case EventQueued:
+		if mine && ev.Order != nil {
+			gs.queueOrder(*ev.Order)
+			fmt.Fprintf(gs.out, "Your %s order is queued for turn %d\n", ev.Order.Kind, ev.Turn)
+		}
+	case EventIncome:
+		if mine {
+			gs.setTreasury(ev.Treasury)
+			fmt.Fprintf(gs.out, "You earned %d gold and have %d in the treasury\n", ev.Income, ev.Treasury)
+		}
+	case EventRejected:
+		if mine {
+			fmt.Fprintf(gs.out, "The server rejected your order: %s\n", ev.Reason)
+		}
+	}
+}
+
+func (gs *GameState) applyWar(ev GameEvent) {
+	war := ev.War
+	if war == nil {
+		return
+	}
+	fmt.Fprintln(gs.out, "==== War Declared ====")
+	fmt.Fprintf(gs.out, "%s has declared war on %s!\n", war.Attacker, war.Defender)
+	for _, battle := range war.Battles {
+		fmt.Fprintf(gs.out, "==== Battle of %s ====\n", battle.Location)
+		printBattle(gs.out, battle)
+		survivors := battle.DefenderSurvivors
+		if war.Attacker == gs.GetUsername() {
+			survivors = battle.AttackerSurvivors
+		}
+		if war.Attacker == gs.GetUsername() || war.Defender == gs.GetUsername() {
+			for _, unit := range survivors {
+				gs.UpdateUnit(unit)
+			}
+		}
+	}
+	if winner, _, draw := war.Outcome(); draw {
+		fmt.Fprintln(gs.out, "The war ended in a draw!")
+	} else {
+		fmt.Fprintf(gs.out, "%s has won the war!\n", winner)
+	}
+
+	killed := []UnitRef{}
+	for _, ref := range ev.Casualties {
+		if ref.Owner == gs.GetUsername() {
+			killed = append(killed, ref)
+		}
+	}
+	if len(killed) == 0 {
+		return
+	}
+	gs.removeUnits(killed)
+	fmt.Fprintf(gs.out, "You lost %v.\n", killed)
+}
+
+func describeMarch(u Unit) string {
+	if len(u.Path) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", marching to %s via %v", u.Path[len(u.Path)-1], u.Path)
+}
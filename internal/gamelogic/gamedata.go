@@ -1,8 +1,12 @@
 package gamelogic
 
+// Player is everything one player owns. NextUnitID is the ID their next
+// unit will get; see AllocateUnitID.
 type Player struct {
-	Username string
-	Units    map[int]Unit
+	Username   string
+	Units      map[int]Unit
+	Treasury   int
+	NextUnitID int
 }
 
 type UnitRank string
@@ -13,24 +17,79 @@ const (
 	RankArtillery = "artillery"
 )
 
+// Unit is a single army piece with Health left out of the board's
+// MaxHealth for its rank. ID is unique within Owner's units. A unit with a
+// Path is on the road: it reaches Path[0] next and carries Progress
+// movement points toward it.
 type Unit struct {
 	ID       int
+	Owner    string
 	Rank     UnitRank
+	Health   int
 	Location Location
+	Path     []Location
+	Progress int
 }
 
-type ArmyMove struct {
-	Player     Player
-	Units      []Unit
-	ToLocation Location
+type Location string
+
+type IntentKind string
+
+const (
+	IntentJoin  IntentKind = "join"
+	IntentSpawn IntentKind = "spawn"
+	IntentMove  IntentKind = "move"
+)
+
+// Intent is what a client asks the server to do on its player's behalf.
+// Spawns and moves are orders for Turn and take effect when it ends. A join
+// asks for the server's record of the player.
+type Intent struct {
+	Kind     IntentKind
+	Username string
+	Turn     int
+	Rank     UnitRank
+	Location Location
+	UnitIDs  []int
 }
 
-type RecognitionOfWar struct {
-	Attacker Player
-	Defender Player
+type EventKind string
+
+const (
+	EventSync     EventKind = "sync"
+	EventSpawned  EventKind = "spawned"
+	EventMoved    EventKind = "moved"
+	EventWar      EventKind = "war"
+	EventRejected EventKind = "rejected"
+	EventQueued   EventKind = "queued"
+	EventIncome   EventKind = "income"
+)
+
+// GameEvent is a change to the world made by the server. Username is the
+// player the event is about.
+type GameEvent struct {
+	Kind     EventKind
+	Username string
+	Turn     int
+	// TurnOpen and Paused tell a syncing client whether it may send orders
+	// for Turn.
+	TurnOpen   bool
+	Paused     bool
+	Units      []Unit
+	Location   Location
+	Player     *Player
+	War        *WarResult
+	Casualties []UnitRef
+	Order      *Intent
+	Income     int
+	Treasury   int
+	Reason     string
 }
 
-type Location string
+// Ranks lists every unit rank from weakest to strongest.
+func Ranks() []UnitRank {
+	return []UnitRank{RankInfantry, RankCavalry, RankArtillery}
+}
 
 func getAllRanks() map[UnitRank]struct{} {
 	return map[UnitRank]struct{}{
@@ -39,14 +98,3 @@ func getAllRanks() map[UnitRank]struct{} {
 		RankArtillery: {},
 	}
 }
-
-func getAllLocations() map[Location]struct{} {
-	return map[Location]struct{}{
-		"americas":   {},
-		"europe":     {},
-		"africa":     {},
-		"asia":       {},
-		"australia":  {},
-		"antarctica": {},
-	}
-}
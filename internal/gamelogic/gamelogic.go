@@ -17,10 +17,14 @@ func PrintClientHelp() {
 	fmt.Println("* spawn <location> <rank>")
 	fmt.Println("    example:")
 	fmt.Println("    spawn europe infantry")
+	fmt.Println("    units cost gold and can only spawn where you control the location")
 	fmt.Println("* status")
-	fmt.Println("* spam <n>")
+	fmt.Println("* save [path]")
+	fmt.Println("* load [path]")
+	fmt.Println("* spam <n> [-rate <msgs/sec>] [-duration <time>] [-concurrency <workers>]")
 	fmt.Println("    example:")
 	fmt.Println("    spam 5")
+	fmt.Println("    spam 1000 -rate 200 -concurrency 4")
 	fmt.Println("* quit")
 	fmt.Println("* help")
 }
@@ -77,16 +81,33 @@ func PrintQuit() {
 }
 
 func (gs *GameState) CommandStatus() {
-	if gs.isPaused() {
-		fmt.Println("The game is paused.")
+	if gs.IsPaused() {
+		fmt.Fprintln(gs.out, "The game is paused.")
 		return
 	} else {
-		fmt.Println("The game is not paused.")
+		fmt.Fprintln(gs.out, "The game is not paused.")
 	}
 
 	p := gs.GetPlayerSnap()
-	fmt.Printf("You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	board := gs.Board()
+	fmt.Fprintf(gs.out, "You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	fmt.Fprintf(gs.out, "Your treasury holds %d gold.\n", p.Treasury)
 	for _, unit := range p.Units {
-		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+		fmt.Fprintf(gs.out, "* %v: %v, %v (%d/%d health)%s\n", unit.ID, unit.Location, unit.Rank, board.healthy(unit).Health, board.MaxHealth(unit.Rank), describeMarch(unit))
+	}
+
+	turn, open := gs.CurrentTurn()
+	if !open {
+		fmt.Fprintln(gs.out, "Waiting for the next turn.")
+		return
+	}
+	orders := gs.Orders()
+	fmt.Fprintf(gs.out, "It is turn %d, and you have %d orders queued.\n", turn, len(orders))
+	for _, o := range orders {
+		if o.Kind == IntentSpawn {
+			fmt.Fprintf(gs.out, "* spawn %v in %v\n", o.Rank, o.Location)
+			continue
+		}
+		fmt.Fprintf(gs.out, "* move %v to %v\n", o.UnitIDs, o.Location)
 	}
 }
@@ -1,13 +1,20 @@
 package gamelogic
 
 import (
+	"io"
+	"os"
 	"sync"
 )
 
 type GameState struct {
-	Player Player
-	Paused bool
-	mu     *sync.RWMutex
+	Player   Player
+	Paused   bool
+	turn     int
+	turnOpen bool
+	orders   []Intent
+	board    *Board
+	out      io.Writer
+	mu       *sync.RWMutex
 }
 
 func NewGameState(username string) *GameState {
@@ -17,10 +24,32 @@ func NewGameState(username string) *GameState {
 			Units:    map[int]Unit{},
 		},
 		Paused: false,
+		board:  DefaultBoard(),
+		out:    os.Stdout,
 		mu:     &sync.RWMutex{},
 	}
 }
 
+// SetBoard switches to a different map. It must match the server's board,
+// or moves the client allows will be rejected.
+func (gs *GameState) SetBoard(b *Board) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.board = b
+}
+
+// SetOutput sends everything the game state reports to w instead of
+// stdout. Call it before the game starts.
+func (gs *GameState) SetOutput(w io.Writer) {
+	gs.out = w
+}
+
+func (gs *GameState) Board() *Board {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.board
+}
+
 func (gs *GameState) resumeGame() {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
@@ -33,28 +62,88 @@ func (gs *GameState) pauseGame() {
 	gs.Paused = true
 }
 
-func (gs *GameState) isPaused() bool {
+func (gs *GameState) IsPaused() bool {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 	return gs.Paused
 }
 
+// CurrentTurn returns the turn orders should be sent for, or false if no
+// turn is open.
+func (gs *GameState) CurrentTurn() (int, bool) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.turn, gs.turnOpen
+}
+
+func (gs *GameState) setTurn(turn int, open bool) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if turn != gs.turn {
+		gs.orders = nil
+	}
+	gs.turn = turn
+	gs.turnOpen = open
+}
+
+func (gs *GameState) queueOrder(in Intent) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if in.Turn == gs.turn {
+		gs.orders = append(gs.orders, in)
+	}
+}
+
+// Orders returns the orders the server has accepted for the current turn.
+func (gs *GameState) Orders() []Intent {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return append([]Intent{}, gs.orders...)
+}
+
 func (gs *GameState) addUnit(u Unit) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
 	gs.Player.Units[u.ID] = u
+	gs.Player.ReserveUnitID(u.ID)
 }
 
-func (gs *GameState) removeUnitsInLocation(loc Location) {
+// removeUnits removes the refs that belong to us and ignores the rest.
+func (gs *GameState) removeUnits(refs []UnitRef) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
+	for _, ref := range refs {
+		if ref.Owner == gs.Player.Username {
+			delete(gs.Player.Units, ref.ID)
 		}
 	}
 }
 
+func (gs *GameState) setUnits(units map[int]Unit) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.Player.Units = map[int]Unit{}
+	for k, v := range units {
+		gs.Player.Units[k] = v
+		gs.Player.ReserveUnitID(k)
+	}
+}
+
+// syncPlayer takes on the server's record of our player.
+func (gs *GameState) syncPlayer(p Player) {
+	gs.setUnits(p.Units)
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.Player.Treasury = p.Treasury
+	gs.Player.ReserveUnitID(p.NextUnitID - 1)
+}
+
+func (gs *GameState) setTreasury(treasury int) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.Player.Treasury = treasury
+}
+
 func (gs *GameState) UpdateUnit(u Unit) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
@@ -90,7 +179,9 @@ func (gs *GameState) GetPlayerSnap() Player {
 		Units[k] = v
 	}
 	return Player{
-		Username: gs.Player.Username,
-		Units:    Units,
+		Username:   gs.Player.Username,
+		Units:      Units,
+		Treasury:   gs.Player.Treasury,
+		NextUnitID: gs.Player.NextUnitID,
 	}
 }
@@ -3,90 +3,58 @@ package gamelogic
 import (
 	"errors"
 	"fmt"
-	"strconv"
 )
 
-type MoveOutcome int
-
-const (
-	MoveOutcomeSamePlayer MoveOutcome = iota
-	MoveOutComeSafe
-	MoveOutcomeMakeWar
-)
-
-func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
-	defer fmt.Println("------------------------")
-	player := gs.GetPlayerSnap()
-
-	fmt.Println()
-	fmt.Println("==== Move Detected ====")
-	fmt.Printf("%s is moving %v unit(s) to %s\n", move.Player.Username, len(move.Units), move.ToLocation)
-	for _, unit := range move.Units {
-		fmt.Printf("* %v\n", unit.Rank)
-	}
-
-	if player.Username == move.Player.Username {
-		return MoveOutcomeSamePlayer
-	}
-
-	overlappingLocation := getOverlappingLocation(player, move.Player)
-	if overlappingLocation != "" {
-		fmt.Printf("You have units in %s! You are at war with %s!\n", overlappingLocation, move.Player.Username)
-		return MoveOutcomeMakeWar
-	}
-	fmt.Printf("You are safe from %s's units.\n", move.Player.Username)
-	return MoveOutComeSafe
-}
-
-func getOverlappingLocation(p1 Player, p2 Player) Location {
-	for _, u1 := range p1.Units {
-		for _, u2 := range p2.Units {
-			if u1.Location == u2.Location {
-				return u1.Location
-			}
-		}
+// CommandMove checks a move command against the units we know about and the
+// board, and turns it into an order for the current turn; the server has the
+// final say. Units further away than one turn's movement march over several
+// turns.
+func (gs *GameState) CommandMove(words []string) (Intent, error) {
+	if gs.IsPaused() {
+		return Intent{}, errors.New("the game is paused, you can not move units")
 	}
-	return ""
-}
-
-func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
-	if gs.isPaused() {
-		return ArmyMove{}, errors.New("the game is paused, you can not move units")
+	turn, err := gs.orderTurn()
+	if err != nil {
+		return Intent{}, err
 	}
 	if len(words) < 3 {
-		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
+		return Intent{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
 	}
+	board := gs.Board()
 	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
-		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
+	if !board.HasLocation(newLocation) {
+		return Intent{}, fmt.Errorf("error: %s is %w", newLocation, ErrUnknownLocation)
 	}
 	unitIDs := []int{}
+	turns := 0
 	for _, word := range words[2:] {
-		id := word
-		unitID, err := strconv.Atoi(id)
+		ref, err := ParseUnitRef(word, gs.GetUsername())
 		if err != nil {
-			return ArmyMove{}, fmt.Errorf("error: %s is not a valid unit ID", id)
+			return Intent{}, fmt.Errorf("error: %v", err)
 		}
-		unitIDs = append(unitIDs, unitID)
-	}
-
-	newUnits := []Unit{}
-	for _, unitID := range unitIDs {
+		if ref.Owner != gs.GetUsername() {
+			return Intent{}, fmt.Errorf("error: %v belongs to %s", ref, ref.Owner)
+		}
+		unitID := ref.ID
 		unit, ok := gs.GetUnit(unitID)
 		if !ok {
-			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
+			return Intent{}, fmt.Errorf("error: unit with ID %v not found", unitID)
+		}
+		path, err := board.Path(unit.Location, newLocation)
+		if err != nil {
+			return Intent{}, fmt.Errorf("error: unit %v can't move: %w", unitID, err)
 		}
-		unit.Location = newLocation
-		gs.UpdateUnit(unit)
-		newUnits = append(newUnits, unit)
+		unit.Path, unit.Progress = path, 0
+		turns = max(turns, board.TurnsToArrive(unit))
+		unitIDs = append(unitIDs, unitID)
 	}
 
-	mv := ArmyMove{
-		ToLocation: newLocation,
-		Units:      newUnits,
-		Player:     gs.GetPlayerSnap(),
-	}
-	fmt.Printf("Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
-	return mv, nil
+	fmt.Fprintf(gs.out, "Ordering %v units to %s for turn %d, arriving in %d turn(s)\n", len(unitIDs), newLocation, turn, turns)
+	return Intent{
+		Kind:     IntentMove,
+		Username: gs.GetUsername(),
+		Turn:     turn,
+		Location: newLocation,
+		UnitIDs:  unitIDs,
+	}, nil
 }
@@ -0,0 +1,190 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const snapshotDir = "saves"
+
+// Snapshot is everything a client needs to pick a game back up after a
+// restart.
+type Snapshot struct {
+	Player  Player
+	Paused  bool
+	SavedAt time.Time
+}
+
+// SnapshotPath is where a player's game is saved when no path is given.
+func SnapshotPath(username string) string {
+	return filepath.Join(snapshotDir, username+".json")
+}
+
+func (gs *GameState) Snapshot() Snapshot {
+	return Snapshot{
+		Player:  gs.GetPlayerSnap(),
+		Paused:  gs.IsPaused(),
+		SavedAt: time.Now(),
+	}
+}
+
+// Restore replaces the game state with snap. Snapshots belong to a single
+// player, so restoring someone else's is an error.
+func (gs *GameState) Restore(snap Snapshot) error {
+	if snap.Player.Username != gs.GetUsername() {
+		return fmt.Errorf("snapshot belongs to %s, not %s", snap.Player.Username, gs.GetUsername())
+	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.Paused = snap.Paused
+	gs.Player.Treasury = snap.Player.Treasury
+	gs.Player.Units = map[int]Unit{}
+	gs.Player.NextUnitID = snap.Player.NextUnitID
+	for k, v := range snap.Player.Units {
+		v.ID, v.Owner = k, gs.Player.Username
+		gs.Player.Units[k] = v
+		gs.Player.ReserveUnitID(k)
+	}
+	return nil
+}
+
+// SaveGameState writes a snapshot of gs to path. The file is replaced
+// atomically so a crash mid-save never leaves a corrupt game behind.
+func SaveGameState(gs *GameState, path string) error {
+	data, err := json.MarshalIndent(gs.Snapshot(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode game state: %v", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create save directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("could not create save file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write save file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write save file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not replace save file: %v", err)
+	}
+	return nil
+}
+
+// LoadGameState reads a snapshot written by SaveGameState. The error wraps
+// os.ErrNotExist when there is no save at path.
+func LoadGameState(path string) (Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Snapshot{}, fmt.Errorf("no saved game at %s: %w", path, err)
+		}
+		return Snapshot{}, fmt.Errorf("could not read save file: %v", err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("could not decode save file: %v", err)
+	}
+	if snap.Player.Units == nil {
+		snap.Player.Units = map[int]Unit{}
+	}
+	return snap, nil
+}
+
+// WorldSnapshotPath is where the server saves the world when no path is
+// given.
+var WorldSnapshotPath = filepath.Join(snapshotDir, "world.json")
+
+// WorldSnapshot is the server's record of a game, so that a restarted
+// server picks up where it left off instead of trusting what clients say
+// they had.
+type WorldSnapshot struct {
+	Turn    int
+	Players []Player
+	SavedAt time.Time
+}
+
+func (w *World) Snapshot() WorldSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	snap := WorldSnapshot{Turn: w.turn, SavedAt: time.Now()}
+	for _, name := range w.usernames() {
+		snap.Players = append(snap.Players, snapshot(w.players[name]))
+	}
+	return snap
+}
+
+// Restore replaces every player with the ones in snap. Units that don't fit
+// the world's board, because it changed since the save, are dropped.
+func (w *World) Restore(snap WorldSnapshot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.turn = snap.Turn
+	w.open = false
+	w.orders = map[string]*orders{}
+	w.players = map[string]*Player{}
+	for _, saved := range snap.Players {
+		if saved.Username == "" {
+			continue
+		}
+		player := &Player{Username: saved.Username, Units: map[int]Unit{}, Treasury: max(saved.Treasury, 0)}
+		player.ReserveUnitID(saved.NextUnitID - 1)
+		for id, unit := range saved.Units {
+			unit.ID, unit.Owner = id, player.Username
+			player.ReserveUnitID(id)
+			if !w.board.HasLocation(unit.Location) {
+				continue
+			}
+			if _, ok := getAllRanks()[unit.Rank]; !ok {
+				continue
+			}
+			if err := w.checkRoute(unit.Location, unit.Path); err != nil {
+				unit.Path, unit.Progress = nil, 0
+			}
+			player.Units[id] = w.board.healthy(unit)
+		}
+		w.players[player.Username] = player
+	}
+}
+
+// SaveWorld writes a snapshot of w to path, replacing it atomically.
+func SaveWorld(w *World, path string) error {
+	data, err := json.MarshalIndent(w.Snapshot(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode world: %v", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// LoadWorld reads a snapshot written by SaveWorld. The error wraps
+// os.ErrNotExist when there is no save at path.
+func LoadWorld(path string) (WorldSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return WorldSnapshot{}, fmt.Errorf("no saved world at %s: %w", path, err)
+		}
+		return WorldSnapshot{}, fmt.Errorf("could not read save file: %v", err)
+	}
+
+	var snap WorldSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return WorldSnapshot{}, fmt.Errorf("could not decode save file: %v", err)
+	}
+	return snap, nil
+}
@@ -0,0 +1,139 @@
+package gamelogic
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoadGameState(t *testing.T) {
+	gs := NewGameState("alice")
+	gs.Paused = true
+	gs.Player.Treasury = 7
+	gs.Player.NextUnitID = 3
+	gs.Player.Units = map[int]Unit{
+		1: {ID: 1, Owner: "alice", Rank: RankInfantry, Health: 3, Location: "europe"},
+		2: {ID: 2, Owner: "alice", Rank: RankCavalry, Health: 5, Location: "asia", Path: []Location{"australia"}, Progress: 1},
+	}
+
+	// the save directory doesn't exist yet
+	path := filepath.Join(t.TempDir(), "saves", "alice.json")
+	if err := SaveGameState(gs, path); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := LoadGameState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snap.Player, gs.Player) {
+		t.Errorf("loaded player %+v, want %+v", snap.Player, gs.Player)
+	}
+	if !snap.Paused {
+		t.Error("loaded game isn't paused")
+	}
+
+	_, err = LoadGameState(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v loading a missing save, want os.ErrNotExist", err)
+	}
+}
+
+func TestGameStateRestore(t *testing.T) {
+	gs := NewGameState("alice")
+	gs.Player.Units[9] = Unit{ID: 9, Owner: "alice", Rank: RankInfantry, Location: "europe"}
+
+	snap := Snapshot{
+		Player: Player{
+			Username: "alice",
+			Treasury: 4,
+			Units: map[int]Unit{
+				// ID and Owner are taken from the key and the player
+				5: {ID: 1, Owner: "mallory", Rank: RankArtillery, Health: 5, Location: "asia"},
+			},
+		},
+		Paused: true,
+	}
+	if err := gs.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Player{
+		Username:   "alice",
+		Treasury:   4,
+		NextUnitID: 6,
+		Units: map[int]Unit{
+			5: {ID: 5, Owner: "alice", Rank: RankArtillery, Health: 5, Location: "asia"},
+		},
+	}
+	if got := gs.GetPlayerSnap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored player %+v, want %+v", got, want)
+	}
+	if !gs.IsPaused() {
+		t.Error("restored game isn't paused")
+	}
+
+	snap.Player.Username = "bob"
+	err := gs.Restore(snap)
+	if err == nil || !strings.Contains(err.Error(), "belongs to bob") {
+		t.Errorf("got error %v restoring bob's snapshot, want one saying it belongs to bob", err)
+	}
+}
+
+func TestWorldRestore(t *testing.T) {
+	board := DefaultBoard()
+	snap := WorldSnapshot{
+		Turn: 4,
+		Players: []Player{
+			{
+				Username:   "alice",
+				Treasury:   -3,
+				NextUnitID: 2,
+				Units: map[int]Unit{
+					1: {Rank: RankInfantry, Health: 2, Location: "europe", Path: []Location{"asia", "australia"}, Progress: 1},
+					2: {Rank: RankCavalry, Location: "asia"},
+					3: {Rank: RankInfantry, Location: "europe", Path: []Location{"australia"}, Progress: 1},
+					4: {Rank: RankInfantry, Location: "atlantis"},
+					5: {Rank: "dragon", Location: "europe"},
+				},
+			},
+			{Username: ""},
+		},
+	}
+
+	w := NewWorld(board, nil)
+	w.Restore(snap)
+
+	want := []Player{{
+		Username:   "alice",
+		Treasury:   0,
+		NextUnitID: 6,
+		Units: map[int]Unit{
+			1: {ID: 1, Owner: "alice", Rank: RankInfantry, Health: 2, Location: "europe", Path: []Location{"asia", "australia"}, Progress: 1},
+			2: {ID: 2, Owner: "alice", Rank: RankCavalry, Health: board.MaxHealth(RankCavalry), Location: "asia"},
+			3: {ID: 3, Owner: "alice", Rank: RankInfantry, Health: board.MaxHealth(RankInfantry), Location: "europe"},
+		},
+	}}
+	if got := w.Players(); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored players\n%+v\nwant\n%+v", got, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "world.json")
+	if err := SaveWorld(w, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWorld(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Turn != 4 {
+		t.Errorf("loaded turn %d, want 4", loaded.Turn)
+	}
+	again := NewWorld(board, nil)
+	again.Restore(loaded)
+	if got := again.Players(); !reflect.DeepEqual(got, want) {
+		t.Errorf("players after a save and load\n%+v\nwant\n%+v", got, want)
+	}
+}
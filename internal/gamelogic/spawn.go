@@ -5,30 +5,50 @@ import (
 	"fmt"
 )
 
-func (gs *GameState) CommandSpawn(words []string) error {
+// CommandSpawn checks a spawn command and turns it into an order for the
+// current turn. The unit only exists once the turn ends and the server's
+// spawned event arrives.
+func (gs *GameState) CommandSpawn(words []string) (Intent, error) {
+	if gs.IsPaused() {
+		return Intent{}, errors.New("the game is paused, you can not spawn units")
+	}
+	turn, err := gs.orderTurn()
+	if err != nil {
+		return Intent{}, err
+	}
 	if len(words) < 3 {
-		return errors.New("usage: spawn <location> <rank>")
+		return Intent{}, errors.New("usage: spawn <location> <rank>")
 	}
 
 	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
-		return fmt.Errorf("error: %s is not a valid location", locationName)
+	if !gs.Board().HasLocation(Location(locationName)) {
+		return Intent{}, fmt.Errorf("error: %s is not a valid location", locationName)
 	}
 
 	rank := words[2]
 	units := getAllRanks()
 	if _, ok := units[UnitRank(rank)]; !ok {
-		return fmt.Errorf("error: %s is not a valid unit", rank)
+		return Intent{}, fmt.Errorf("error: %s is not a valid unit", rank)
+	}
+
+	// the server checks who controls the location; we can at least make
+	// sure the treasury covers this on top of what's already ordered
+	left := gs.GetPlayerSnap().Treasury
+	for _, o := range gs.Orders() {
+		if o.Kind == IntentSpawn {
+			left -= gs.Board().Cost(o.Rank)
+		}
+	}
+	if cost := gs.Board().Cost(UnitRank(rank)); left < cost {
+		return Intent{}, fmt.Errorf("error: a(n) %s costs %d and you have %d left this turn", rank, cost, left)
 	}
 
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
-		ID:       id,
+	fmt.Fprintf(gs.out, "Ordering a(n) %s in %s for turn %d\n", rank, locationName, turn)
+	return Intent{
+		Kind:     IntentSpawn,
+		Username: gs.GetUsername(),
+		Turn:     turn,
 		Rank:     UnitRank(rank),
 		Location: Location(locationName),
-	})
-
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
-	return nil
+	}, nil
 }
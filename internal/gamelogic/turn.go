@@ -0,0 +1,30 @@
+package gamelogic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func (gs *GameState) HandleTurn(ts routing.TurnState) {
+	defer fmt.Fprintln(gs.out, "------------------------")
+	fmt.Fprintln(gs.out)
+	if ts.Phase == routing.TurnStart {
+		fmt.Fprintf(gs.out, "==== Turn %d ====\n", ts.Turn)
+		fmt.Fprintf(gs.out, "Send your orders by %s.\n", ts.Deadline.Format(time.Kitchen))
+		gs.setTurn(ts.Turn, true)
+		return
+	}
+	fmt.Fprintf(gs.out, "==== Turn %d is over ====\n", ts.Turn)
+	gs.setTurn(ts.Turn, false)
+}
+
+// orderTurn is the turn a new order should be sent for.
+func (gs *GameState) orderTurn() (int, error) {
+	turn, open := gs.CurrentTurn()
+	if !open {
+		return 0, fmt.Errorf("no turn is open for orders, wait for the next one")
+	}
+	return turn, nil
+}
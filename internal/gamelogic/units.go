@@ -0,0 +1,49 @@
+package gamelogic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnitRef names a unit unambiguously across every client. Unit IDs are only
+// unique within a player, so a bare ID needs its owner alongside it.
+type UnitRef struct {
+	Owner string
+	ID    int
+}
+
+func (r UnitRef) String() string {
+	return fmt.Sprintf("%s#%d", r.Owner, r.ID)
+}
+
+// ParseUnitRef reads a ref written as owner#id. A bare id belongs to
+// defaultOwner.
+func ParseUnitRef(s, defaultOwner string) (UnitRef, error) {
+	owner, id := defaultOwner, s
+	if i := strings.LastIndex(s, "#"); i >= 0 {
+		owner, id = s[:i], s[i+1:]
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 || owner == "" {
+		return UnitRef{}, fmt.Errorf("%s is not a valid unit ID", s)
+	}
+	return UnitRef{Owner: owner, ID: n}, nil
+}
+
+func (u Unit) Ref() UnitRef {
+	return UnitRef{Owner: u.Owner, ID: u.ID}
+}
+
+// AllocateUnitID hands out the next ID for one of p's units. IDs only ever
+// go up, so a dead unit's ID is never given to a new one.
+func (p *Player) AllocateUnitID() int {
+	id := max(p.NextUnitID, 1)
+	p.NextUnitID = id + 1
+	return id
+}
+
+// ReserveUnitID makes sure id is never handed out by AllocateUnitID.
+func (p *Player) ReserveUnitID(id int) {
+	p.NextUnitID = max(p.NextUnitID, id+1)
+}
@@ -0,0 +1,33 @@
+package gamelogic
+
+import "testing"
+
+func TestParseUnitRef(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    UnitRef
+		wantErr bool
+	}{
+		{in: "3", want: UnitRef{Owner: "me", ID: 3}},
+		{in: "bob#12", want: UnitRef{Owner: "bob", ID: 12}},
+		{in: "a#b#2", want: UnitRef{Owner: "a#b", ID: 2}},
+		{in: "0", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "bob#", wantErr: true},
+		{in: "#4", wantErr: true},
+		{in: "x", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseUnitRef(tc.in, "me")
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseUnitRef(%q) = %v, want an error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseUnitRef(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+}
@@ -2,104 +2,160 @@ package gamelogic
 
 import (
 	"fmt"
+	"io"
+	"slices"
 )
 
-type WarOutcome int
-
-const (
-	WarOutcomeNotInvolved WarOutcome = iota
-	WarOutcomeNoUnits
-	WarOutcomeYouWon
-	WarOutcomeOpponentWon
-	WarOutcomeDraw
-)
+func printBattle(w io.Writer, battle BattleResult) {
+	fmt.Fprintf(w, "Attacker has a power level of %v\n", battle.AttackerPower)
+	fmt.Fprintf(w, "Defender has a power level of %v\n", battle.DefenderPower)
+	for i, round := range battle.Rounds {
+		fmt.Fprintf(w, "Round %d: attacker dealt %d damage, defender dealt %d\n", i+1, round.AttackerDamage, round.DefenderDamage)
+	}
+	fmt.Fprintf(w, "Attacker lost %d unit(s), %d survived\n", len(battle.AttackerLosses), len(battle.AttackerSurvivors))
+	fmt.Fprintf(w, "Defender lost %d unit(s), %d survived\n", len(battle.DefenderLosses), len(battle.DefenderSurvivors))
+	if battle.Draw {
+		fmt.Fprintln(w, "The battle ended in a draw!")
+	} else {
+		fmt.Fprintf(w, "%s has won the battle!\n", battle.Winner)
+	}
+}
 
-func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner string, loser string) {
-	defer fmt.Println("------------------------")
-	fmt.Println()
-	fmt.Println("==== War Declared ====")
-	fmt.Printf("%s has declared war on %s!\n", rw.Attacker.Username, rw.Defender.Username)
+// WarResult is the outcome of a war as computed by a neutral observer, so
+// that the server and clients agree on who won. Every location the two
+// players share is fought over separately, in location order.
+type WarResult struct {
+	Attacker string
+	Defender string
+	Battles  []BattleResult
+}
 
-	player := gs.GetPlayerSnap()
+// BattleResult is the outcome of the fighting in a single location.
+// Survivors carry the health they have left.
+type BattleResult struct {
+	Location          Location
+	AttackerPower     int
+	DefenderPower     int
+	Rounds            []BattleRound
+	AttackerLosses    []UnitRef
+	DefenderLosses    []UnitRef
+	AttackerSurvivors []Unit
+	DefenderSurvivors []Unit
+	Winner            string
+	Loser             string
+	Draw              bool
+}
 
-	if player.Username == rw.Defender.Username {
-		fmt.Printf("%s, you published the war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+// Casualties lists every unit either side lost, battle by battle.
+func (r WarResult) Casualties() []UnitRef {
+	refs := []UnitRef{}
+	for _, battle := range r.Battles {
+		refs = append(refs, battle.AttackerLosses...)
+		refs = append(refs, battle.DefenderLosses...)
 	}
+	return refs
+}
 
-	if player.Username != rw.Attacker.Username {
-		fmt.Printf("%s, you are not involved in this war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+// Outcome decides the war as a whole: whoever won more battles won the war,
+// and an even split is a draw.
+func (r WarResult) Outcome() (winner, loser string, draw bool) {
+	score := 0
+	for _, battle := range r.Battles {
+		switch {
+		case battle.Draw:
+		case battle.Winner == r.Attacker:
+			score++
+		default:
+			score--
+		}
 	}
+	switch {
+	case score > 0:
+		return r.Attacker, r.Defender, false
+	case score < 0:
+		return r.Defender, r.Attacker, false
+	}
+	return "", "", true
+}
 
-	overlappingLocation := getOverlappingLocation(rw.Attacker, rw.Defender)
-	if overlappingLocation == "" {
-		fmt.Printf("Error! No units are in the same location. No war will be fought.\n")
-		return WarOutcomeNoUnits, "", ""
+// resolveWar fights the war between the two players' units in every
+// location where they overlap, by board's rules. It reports false if they
+// share no location.
+func resolveWar(board *Board, attacker, defender Player, dice Dice) (WarResult, bool) {
+	locations := getOverlappingLocations(attacker, defender)
+	if len(locations) == 0 {
+		return WarResult{}, false
 	}
 
-	attackerUnits := []Unit{}
-	defenderUnits := []Unit{}
-	for _, unit := range rw.Attacker.Units {
-		if unit.Location == overlappingLocation {
-			attackerUnits = append(attackerUnits, unit)
-		}
+	result := WarResult{
+		Attacker: attacker.Username,
+		Defender: defender.Username,
 	}
-	for _, unit := range rw.Defender.Units {
-		if unit.Location == overlappingLocation {
-			defenderUnits = append(defenderUnits, unit)
+	for _, loc := range locations {
+		attackers := unitsInLocation(board, attacker, loc)
+		defenders := unitsInLocation(board, defender, loc)
+		battle := BattleResult{
+			Location:      loc,
+			AttackerPower: board.PowerLevel(attackers),
+			DefenderPower: board.PowerLevel(defenders),
 		}
-	}
+		fight(board, &battle, attackers, defenders, dice)
 
-	fmt.Printf("%s's units:\n", rw.Attacker.Username)
-	for _, unit := range attackerUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
+		// a side that was wiped out lost; otherwise whoever has more fight
+		// left in them holds the field
+		attackerLeft := board.PowerLevel(battle.AttackerSurvivors)
+		defenderLeft := board.PowerLevel(battle.DefenderSurvivors)
+		switch {
+		case attackerLeft > defenderLeft:
+			battle.Winner, battle.Loser = result.Attacker, result.Defender
+		case defenderLeft > attackerLeft:
+			battle.Winner, battle.Loser = result.Defender, result.Attacker
+		default:
+			battle.Draw = true
+		}
+		result.Battles = append(result.Battles, battle)
 	}
-	fmt.Printf("%s's units:\n", rw.Defender.Username)
-	for _, unit := range defenderUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
+	return result, true
+}
+
+// getOverlappingLocations returns every location both players have units
+// in, sorted so that everyone fights the battles in the same order.
+func getOverlappingLocations(p1 Player, p2 Player) []Location {
+	occupied := map[Location]bool{}
+	for _, u := range p1.Units {
+		occupied[u.Location] = true
 	}
-	attackerPower := unitsToPowerLevel(attackerUnits)
-	defenderPower := unitsToPowerLevel(defenderUnits)
-	fmt.Printf("Attacker has a power level of %v\n", attackerPower)
-	fmt.Printf("Defender has a power level of %v\n", defenderPower)
-	if attackerPower > defenderPower {
-		fmt.Printf("%s has won the war!\n", rw.Attacker.Username)
-		if player.Username == rw.Defender.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Attacker.Username, rw.Defender.Username
+	seen := map[Location]bool{}
+	locations := []Location{}
+	for _, u := range p2.Units {
+		if occupied[u.Location] && !seen[u.Location] {
+			seen[u.Location] = true
+			locations = append(locations, u.Location)
 		}
-		return WarOutcomeYouWon, rw.Attacker.Username, rw.Defender.Username
-	} else if defenderPower > attackerPower {
-		fmt.Printf("%s has won the war!\n", rw.Defender.Username)
-		if player.Username == rw.Attacker.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
+	}
+	slices.Sort(locations)
+	return locations
+}
+
+// unitsInLocation returns p's units in loc ordered by ID.
+func unitsInLocation(board *Board, p Player, loc Location) []Unit {
+	units := []Unit{}
+	for _, unit := range p.Units {
+		if unit.Location == loc {
+			unit.Owner = p.Username
+			units = append(units, board.healthy(unit))
 		}
-		return WarOutcomeYouWon, rw.Defender.Username, rw.Attacker.Username
 	}
-	fmt.Println("The war ended in a draw!")
-	fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-	gs.removeUnitsInLocation(overlappingLocation)
-	return WarOutcomeDraw, rw.Attacker.Username, rw.Defender.Username
+	slices.SortFunc(units, func(a, b Unit) int { return a.ID - b.ID })
+	return units
 }
 
-func unitsToPowerLevel(units []Unit) int {
+// PowerLevel is how hard units hit together, taking their wounds into
+// account.
+func (b *Board) PowerLevel(units []Unit) int {
 	power := 0
 	for _, unit := range units {
-		if unit.Rank == RankArtillery {
-			power += 10
-		}
-		if unit.Rank == RankCavalry {
-			power += 5
-		}
-		if unit.Rank == RankInfantry {
-			power += 1
-		}
+		power += b.unitPower(unit)
 	}
 	return power
 }
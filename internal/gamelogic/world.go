@@ -0,0 +1,376 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// World is the server's canonical record of every player's units. Clients
+// only ever send intents; the world validates them against its own state,
+// holds spawns and moves as orders until the turn ends, and returns the
+// events everyone should apply.
+type World struct {
+	mu      sync.Mutex
+	board   *Board
+	dice    Dice
+	players map[string]*Player
+	paused  bool
+	turn    int
+	open    bool
+	orders  map[string]*orders
+}
+
+// orders are what one player has asked for this turn. A later move for the
+// same unit replaces the earlier one.
+type orders struct {
+	spawns []Intent
+	moves  map[int]Intent
+}
+
+// NewWorld starts an empty game on board. Battles roll dice, or are fought
+// without luck if dice is nil.
+func NewWorld(board *Board, dice Dice) *World {
+	return &World{
+		board:   board,
+		dice:    dice,
+		players: map[string]*Player{},
+		orders:  map[string]*orders{},
+	}
+}
+
+func (w *World) SetPaused(paused bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paused = paused
+}
+
+func (w *World) Paused() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.paused
+}
+
+// Apply validates in and either answers it straight away (joins) or queues
+// it for the current turn. The returned error explains why an intent was
+// rejected; the world is unchanged in that case.
+func (w *World) Apply(in Intent) ([]GameEvent, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if in.Username == "" {
+		return nil, errors.New("intent has no username")
+	}
+	player := w.player(in.Username)
+
+	switch in.Kind {
+	case IntentJoin:
+		// the world's own record is the only one that counts; a player it
+		// has never seen starts from scratch whatever their client saved
+		snap := snapshot(player)
+		return []GameEvent{{
+			Kind:     EventSync,
+			Username: in.Username,
+			Player:   &snap,
+			Turn:     w.turn,
+			TurnOpen: w.open,
+			Paused:   w.paused,
+		}}, nil
+	case IntentSpawn:
+		if err := w.checkOrder(in); err != nil {
+			return nil, err
+		}
+		if err := w.checkSpawn(player, in); err != nil {
+			return nil, err
+		}
+		o := w.ordersFor(in.Username)
+		o.spawns = append(o.spawns, in)
+		return w.queued(in), nil
+	case IntentMove:
+		if err := w.checkOrder(in); err != nil {
+			return nil, err
+		}
+		if err := w.checkMove(player, in); err != nil {
+			return nil, err
+		}
+		o := w.ordersFor(in.Username)
+		for _, id := range in.UnitIDs {
+			o.moves[id] = in
+		}
+		return w.queued(in), nil
+	}
+	return nil, fmt.Errorf("unknown intent %q", in.Kind)
+}
+
+// StartTurn opens the next turn for orders and returns its number.
+func (w *World) StartTurn() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.turn++
+	w.open = true
+	w.orders = map[string]*orders{}
+	return w.turn
+}
+
+// EndTurn closes the current turn and carries out every order at once:
+// spawns first, then all marches, then a war between every pair of players
+// who share a location.
+func (w *World) EndTurn() []GameEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.open = false
+
+	events := []GameEvent{}
+	for _, name := range w.usernames() {
+		o, ok := w.orders[name]
+		if !ok {
+			continue
+		}
+		player := w.players[name]
+		for _, in := range o.spawns {
+			cost := w.board.Cost(in.Rank)
+			if player.Treasury < cost {
+				events = append(events, GameEvent{
+					Kind:     EventRejected,
+					Username: name,
+					Reason:   fmt.Sprintf("you can't afford a(n) %s any more", in.Rank),
+				})
+				continue
+			}
+			player.Treasury -= cost
+			events = append(events, w.spawn(player, in))
+		}
+		for id, in := range o.moves {
+			unit, ok := player.Units[id]
+			if !ok {
+				continue
+			}
+			path, err := w.board.Path(unit.Location, in.Location)
+			if err != nil {
+				continue
+			}
+			unit.Path, unit.Progress = path, 0
+			player.Units[id] = unit
+		}
+	}
+	w.orders = map[string]*orders{}
+
+	for _, name := range w.usernames() {
+		player := w.players[name]
+		moved := []Unit{}
+		for _, id := range sortedUnitIDs(player) {
+			unit := player.Units[id]
+			if len(unit.Path) == 0 {
+				continue
+			}
+			unit = w.board.March(unit)
+			player.Units[id] = unit
+			moved = append(moved, unit)
+		}
+		if len(moved) == 0 {
+			continue
+		}
+		events = append(events, GameEvent{Kind: EventMoved, Username: name, Units: moved})
+	}
+
+	events = append(events, w.wars()...)
+
+	controllers := w.controllers()
+	for _, name := range w.usernames() {
+		player := w.players[name]
+		income := w.board.turnIncome(controllers, name)
+		player.Treasury += income
+		events = append(events, GameEvent{Kind: EventIncome, Username: name, Income: income, Treasury: player.Treasury})
+	}
+	for i := range events {
+		events[i].Turn = w.turn
+	}
+	return events
+}
+
+// Players returns a copy of every player the world knows about.
+func (w *World) Players() []Player {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	players := make([]Player, 0, len(w.players))
+	for _, name := range w.usernames() {
+		players = append(players, snapshot(w.players[name]))
+	}
+	return players
+}
+
+func (w *World) player(username string) *Player {
+	p, ok := w.players[username]
+	if !ok {
+		p = &Player{Username: username, Units: map[int]Unit{}, Treasury: startingTreasury}
+		w.players[username] = p
+	}
+	return p
+}
+
+func (w *World) ordersFor(username string) *orders {
+	o, ok := w.orders[username]
+	if !ok {
+		o = &orders{moves: map[int]Intent{}}
+		w.orders[username] = o
+	}
+	return o
+}
+
+func (w *World) queued(in Intent) []GameEvent {
+	return []GameEvent{{Kind: EventQueued, Username: in.Username, Order: &in, Turn: w.turn}}
+}
+
+func (w *World) usernames() []string {
+	names := make([]string, 0, len(w.players))
+	for name := range w.players {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// checkOrder rejects orders sent while the game can't take them or meant
+// for a turn other than the one that is open.
+func (w *World) checkOrder(in Intent) error {
+	if w.paused {
+		return fmt.Errorf("the game is paused, you can not %s units", in.Kind)
+	}
+	if !w.open {
+		return errors.New("no turn is open for orders")
+	}
+	if in.Turn != w.turn {
+		return fmt.Errorf("orders for turn %d are closed, it is turn %d", in.Turn, w.turn)
+	}
+	return nil
+}
+
+// checkSpawn makes sure the player may spawn there and can pay for it along
+// with every spawn they've already ordered this turn.
+func (w *World) checkSpawn(player *Player, in Intent) error {
+	if !w.board.HasLocation(in.Location) {
+		return fmt.Errorf("%s is not a valid location", in.Location)
+	}
+	if _, ok := getAllRanks()[in.Rank]; !ok {
+		return fmt.Errorf("%s is not a valid unit", in.Rank)
+	}
+	if err := CanSpawnAt(w.controllers(), player.Username, in.Location); err != nil {
+		return err
+	}
+
+	committed := 0
+	if o, ok := w.orders[player.Username]; ok {
+		for _, queued := range o.spawns {
+			committed += w.board.Cost(queued.Rank)
+		}
+	}
+	if cost := w.board.Cost(in.Rank); player.Treasury-committed < cost {
+		return fmt.Errorf("a(n) %s costs %d and you have %d left this turn", in.Rank, cost, player.Treasury-committed)
+	}
+	return nil
+}
+
+func (w *World) controllers() map[Location]string {
+	players := make([]Player, 0, len(w.players))
+	for _, p := range w.players {
+		players = append(players, *p)
+	}
+	return Controllers(players)
+}
+
+func (w *World) checkMove(player *Player, in Intent) error {
+	if !w.board.HasLocation(in.Location) {
+		return fmt.Errorf("%s is not a valid location", in.Location)
+	}
+	if len(in.UnitIDs) == 0 {
+		return errors.New("no units to move")
+	}
+	for _, id := range in.UnitIDs {
+		unit, ok := player.Units[id]
+		if !ok {
+			return fmt.Errorf("unit with ID %v not found", id)
+		}
+		if _, err := w.board.Path(unit.Location, in.Location); err != nil {
+			return fmt.Errorf("unit %v can't move: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (w *World) spawn(player *Player, in Intent) GameEvent {
+	unit := Unit{
+		ID:       player.AllocateUnitID(),
+		Owner:    player.Username,
+		Rank:     in.Rank,
+		Health:   w.board.MaxHealth(in.Rank),
+		Location: in.Location,
+	}
+	player.Units[unit.ID] = unit
+	return GameEvent{Kind: EventSpawned, Username: player.Username, Units: []Unit{unit}, Location: in.Location, Treasury: player.Treasury}
+}
+
+// wars fights a war between every pair of players sharing a location, once
+// per pair. The player first in username order is the attacker.
+func (w *World) wars() []GameEvent {
+	events := []GameEvent{}
+	names := w.usernames()
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			attacker, defender := w.players[a], w.players[b]
+			result, ok := resolveWar(w.board, snapshot(attacker), snapshot(defender), w.dice)
+			if !ok {
+				continue
+			}
+
+			for _, battle := range result.Battles {
+				w.applyBattle(attacker, battle.AttackerLosses, battle.AttackerSurvivors)
+				w.applyBattle(defender, battle.DefenderLosses, battle.DefenderSurvivors)
+			}
+			events = append(events, GameEvent{
+				Kind:       EventWar,
+				Username:   a,
+				War:        &result,
+				Casualties: result.Casualties(),
+			})
+		}
+	}
+	return events
+}
+
+// checkRoute makes sure path is a walk along the board's edges from loc.
+func (w *World) checkRoute(loc Location, path []Location) error {
+	for _, next := range path {
+		if !slices.Contains(w.board.Neighbours(loc), next) {
+			return fmt.Errorf("%s to %s: %w", loc, next, ErrNoPath)
+		}
+		loc = next
+	}
+	return nil
+}
+
+func (w *World) applyBattle(p *Player, killed []UnitRef, survivors []Unit) {
+	for _, ref := range killed {
+		delete(p.Units, ref.ID)
+	}
+	for _, unit := range survivors {
+		p.Units[unit.ID] = unit
+	}
+}
+
+func sortedUnitIDs(p *Player) []int {
+	ids := make([]int, 0, len(p.Units))
+	for id := range p.Units {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func snapshot(p *Player) Player {
+	units := map[int]Unit{}
+	for k, v := range p.Units {
+		units[k] = v
+	}
+	return Player{Username: p.Username, Units: units, Treasury: p.Treasury, NextUnitID: p.NextUnitID}
+}
@@ -0,0 +1,189 @@
+package gamelogic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWorldApply(t *testing.T) {
+	spawn := func(turn int, rank UnitRank, loc Location) Intent {
+		return Intent{Kind: IntentSpawn, Username: "alice", Turn: turn, Rank: rank, Location: loc}
+	}
+	tests := []struct {
+		name    string
+		setup   func(w *World)
+		in      Intent
+		wantErr string
+	}{
+		{
+			name:  "spawn while the turn is open",
+			setup: func(w *World) { w.StartTurn() },
+			in:    spawn(1, RankInfantry, "europe"),
+		},
+		{
+			name:    "no turn open",
+			in:      spawn(0, RankInfantry, "europe"),
+			wantErr: "no turn is open",
+		},
+		{
+			name: "paused",
+			setup: func(w *World) {
+				w.StartTurn()
+				w.SetPaused(true)
+			},
+			in:      spawn(1, RankInfantry, "europe"),
+			wantErr: "paused",
+		},
+		{
+			name: "stale turn",
+			setup: func(w *World) {
+				w.StartTurn()
+				w.EndTurn()
+				w.StartTurn()
+			},
+			in:      spawn(1, RankInfantry, "europe"),
+			wantErr: "closed",
+		},
+		{
+			name:    "unknown location",
+			setup:   func(w *World) { w.StartTurn() },
+			in:      spawn(1, RankInfantry, "atlantis"),
+			wantErr: "not a valid location",
+		},
+		{
+			name:    "unknown rank",
+			setup:   func(w *World) { w.StartTurn() },
+			in:      spawn(1, "dragon", "europe"),
+			wantErr: "not a valid unit",
+		},
+		{
+			name: "over budget with earlier orders",
+			setup: func(w *World) {
+				w.StartTurn()
+				w.Apply(spawn(1, RankArtillery, "europe"))
+				w.Apply(spawn(1, RankArtillery, "europe"))
+			},
+			in:      spawn(1, RankInfantry, "europe"),
+			wantErr: "you have 0 left",
+		},
+		{
+			name:    "move a unit that doesn't exist",
+			setup:   func(w *World) { w.StartTurn() },
+			in:      Intent{Kind: IntentMove, Username: "alice", Turn: 1, Location: "asia", UnitIDs: []int{1}},
+			wantErr: "not found",
+		},
+		{
+			name:    "no username",
+			in:      Intent{Kind: IntentJoin},
+			wantErr: "no username",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWorld(DefaultBoard(), nil)
+			if tc.setup != nil {
+				tc.setup(w)
+			}
+			events, err := w.Apply(tc.in)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || events[0].Kind != EventQueued {
+				t.Errorf("got events %+v, want the order queued", events)
+			}
+		})
+	}
+}
+
+func TestWorldJoin(t *testing.T) {
+	w := NewWorld(DefaultBoard(), nil)
+	events, err := w.Apply(Intent{Kind: IntentJoin, Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Kind != EventSync || events[0].Player == nil {
+		t.Fatalf("got events %+v, want a sync", events)
+	}
+	if got := events[0].Player.Treasury; got != startingTreasury {
+		t.Errorf("new player has %d gold, want %d", got, startingTreasury)
+	}
+}
+
+func TestWorldEndTurn(t *testing.T) {
+	w := NewWorld(DefaultBoard(), nil)
+	turn := w.StartTurn()
+	for _, in := range []Intent{
+		{Kind: IntentSpawn, Username: "alice", Turn: turn, Rank: RankArtillery, Location: "europe"},
+		{Kind: IntentSpawn, Username: "alice", Turn: turn, Rank: RankArtillery, Location: "europe"},
+		{Kind: IntentSpawn, Username: "bob", Turn: turn, Rank: RankCavalry, Location: "europe"},
+		{Kind: IntentSpawn, Username: "carol", Turn: turn, Rank: RankInfantry, Location: "americas"},
+	} {
+		if _, err := w.Apply(in); err != nil {
+			t.Fatalf("%s's spawn: %v", in.Username, err)
+		}
+	}
+
+	counts := map[EventKind]int{}
+	for _, ev := range w.EndTurn() {
+		counts[ev.Kind]++
+		if ev.Turn != turn {
+			t.Errorf("%s event is for turn %d, want %d", ev.Kind, ev.Turn, turn)
+		}
+	}
+	want := map[EventKind]int{EventSpawned: 4, EventWar: 1, EventIncome: 3}
+	for kind, n := range want {
+		if counts[kind] != n {
+			t.Errorf("got %d %s events, want %d", counts[kind], kind, n)
+		}
+	}
+
+	wantPlayers := map[string]struct{ units, treasury int }{
+		// both artillery survive, and alice now holds europe
+		"alice": {2, 0 + baseIncome + 3},
+		// bob's cavalry fell in the war
+		"bob":   {0, 10 - 3 + baseIncome},
+		"carol": {1, 10 - 1 + baseIncome + 3},
+	}
+	for _, p := range w.Players() {
+		want := wantPlayers[p.Username]
+		if len(p.Units) != want.units || p.Treasury != want.treasury {
+			t.Errorf("%s has %d units and %d gold, want %d and %d", p.Username, len(p.Units), p.Treasury, want.units, want.treasury)
+		}
+	}
+
+	if _, err := w.Apply(Intent{Kind: IntentSpawn, Username: "alice", Turn: turn, Rank: RankInfantry, Location: "europe"}); err == nil {
+		t.Error("accepted an order after the turn ended")
+	}
+}
+
+func TestWorldMove(t *testing.T) {
+	w := NewWorld(DefaultBoard(), nil)
+	turn := w.StartTurn()
+	if _, err := w.Apply(Intent{Kind: IntentSpawn, Username: "alice", Turn: turn, Rank: RankInfantry, Location: "americas"}); err != nil {
+		t.Fatal(err)
+	}
+	w.EndTurn()
+
+	turn = w.StartTurn()
+	if _, err := w.Apply(Intent{Kind: IntentMove, Username: "alice", Turn: turn, Location: "africa", UnitIDs: []int{1}}); err != nil {
+		t.Fatal(err)
+	}
+	w.EndTurn()
+
+	// americas to africa is two edges of cost 2, and infantry marches 2 a turn
+	for _, wantLoc := range []Location{"europe", "africa"} {
+		unit := w.Players()[0].Units[1]
+		if unit.Location != wantLoc {
+			t.Fatalf("unit is in %s, want %s", unit.Location, wantLoc)
+		}
+		w.StartTurn()
+		w.EndTurn()
+	}
+}
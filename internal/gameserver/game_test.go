@@ -0,0 +1,169 @@
+package gameserver
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub/memory"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const (
+	testTurn     = 300 * time.Millisecond
+	testDeadline = 5 * time.Second
+)
+
+// TestGame plays a few turns with the server and two clients sharing one
+// in-memory broker.
+func TestGame(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker, err := memory.NewBroker().Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	names := routing.DefaultNames()
+
+	chnl, err := broker.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.ApplyTopology(chnl, routing.ServerTopology(names)); err != nil {
+		t.Fatal(err)
+	}
+	world := gamelogic.NewWorld(gamelogic.DefaultBoard(), nil)
+	sub, err := SubscribeIntents(ctx, broker, names, world, chnl, pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	go RunTurns(ctx, world, chnl, names, testTurn, "")
+
+	alice := joinTestGame(ctx, t, broker, names, "alice")
+	bob := joinTestGame(ctx, t, broker, names, "bob")
+
+	turn := waitFor(t, "an open turn", func() (int, bool) {
+		turn, open := alice.state.CurrentTurn()
+		bobTurn, bobOpen := bob.state.CurrentTurn()
+		return turn, open && bobOpen && turn == bobTurn
+	})
+	alice.order(t, "spawn", "europe", "infantry")
+	bob.order(t, "spawn", "asia", "cavalry")
+
+	// bob can't order for alice: the key he publishes with gives him away
+	spoofed := gamelogic.Intent{Kind: gamelogic.IntentSpawn, Username: "alice", Turn: turn, Rank: gamelogic.RankArtillery, Location: "europe"}
+	if err := pubsub.PublishJSON(bob.publisher, names.Topic, routing.IntentKey("bob"), spoofed); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "both spawns", func() (int, bool) {
+		return 0, len(alice.state.GetPlayerSnap().Units) == 1 && len(bob.state.GetPlayerSnap().Units) == 1
+	})
+	// give a spoofed spawn time to land if it were going to
+	waitFor(t, "the next turn", func() (int, bool) {
+		next, open := alice.state.CurrentTurn()
+		return next, open && next > turn
+	})
+
+	for _, p := range world.Players() {
+		var client *testClient
+		switch p.Username {
+		case "alice":
+			client = alice
+		case "bob":
+			client = bob
+		default:
+			t.Fatalf("world has an unexpected player %s", p.Username)
+		}
+		if len(p.Units) != 1 {
+			t.Errorf("%s has %d units in the world, want 1", p.Username, len(p.Units))
+		}
+		local := client.state.GetPlayerSnap()
+		for id, unit := range p.Units {
+			if !reflect.DeepEqual(local.Units[id], unit) {
+				t.Errorf("%s's client has %+v, the world has %+v", p.Username, local.Units[id], unit)
+			}
+		}
+	}
+}
+
+type testClient struct {
+	state     *gamelogic.GameState
+	publisher pubsub.Publisher
+	names     routing.Names
+}
+
+func joinTestGame(ctx context.Context, t *testing.T, broker pubsub.Broker, names routing.Names, username string) *testClient {
+	t.Helper()
+	chnl, err := broker.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.ApplyTopology(chnl, routing.ClientTopology(names, username)); err != nil {
+		t.Fatal(err)
+	}
+	state := gamelogic.NewGameState(username)
+	state.SetOutput(io.Discard)
+
+	turnSub, err := pubsub.SubscribeJSON(ctx, broker, names.Direct, routing.TurnQueue(username), routing.TurnKey, pubsub.TransientQueue,
+		func(ts routing.TurnState) pubsub.AckType {
+			state.HandleTurn(ts)
+			return pubsub.Ack
+		}, pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventSub, err := pubsub.SubscribeJSON(ctx, broker, names.Topic, routing.EventsQueue(username), routing.AllKeys(routing.EventsPrefix), pubsub.TransientQueue,
+		func(ev gamelogic.GameEvent) pubsub.AckType {
+			state.ApplyEvent(ev)
+			return pubsub.Ack
+		}, pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		turnSub.Close()
+		eventSub.Close()
+	})
+
+	c := &testClient{state: state, publisher: chnl, names: names}
+	c.publish(t, gamelogic.Intent{Kind: gamelogic.IntentJoin, Username: username})
+	return c
+}
+
+func (c *testClient) order(t *testing.T, words ...string) {
+	t.Helper()
+	intent, err := c.state.CommandSpawn(words)
+	if err != nil {
+		t.Fatalf("%s: %v", c.state.GetUsername(), err)
+	}
+	c.publish(t, intent)
+}
+
+func (c *testClient) publish(t *testing.T, intent gamelogic.Intent) {
+	t.Helper()
+	if err := pubsub.PublishJSON(c.publisher, c.names.Topic, routing.IntentKey(intent.Username), intent); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor polls done until it reports true and returns its value.
+func waitFor(t *testing.T, what string, done func() (int, bool)) int {
+	t.Helper()
+	deadline := time.Now().Add(testDeadline)
+	for time.Now().Before(deadline) {
+		if v, ok := done(); ok {
+			return v
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+	return 0
+}
@@ -0,0 +1,69 @@
+// Package gameserver runs the authoritative side of a Peril game: it applies
+// players' intents to a World, drives the turns and broadcasts the events.
+// cmd/server wraps it in a REPL; cmd/bot can run it in-process.
+package gameserver
+
+import (
+	"context"
+	"log"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// SubscribeIntents feeds every player's intents to world one at a time and
+// broadcasts the results with publisher, on the exchanges names gives.
+func SubscribeIntents(ctx context.Context, broker pubsub.Broker, names routing.Names, world *gamelogic.World, publisher pubsub.Publisher, opts ...pubsub.SubscribeOption) (*pubsub.Subscription, error) {
+	return pubsub.SubscribeJSONKeyed(
+		ctx,
+		broker,
+		names.Topic,
+		routing.IntentsQueue,
+		routing.AllKeys(routing.IntentsPrefix),
+		pubsub.DurableQueue,
+		HandleIntent(world, publisher, names),
+		append([]pubsub.SubscribeOption{pubsub.WithNames(names)}, opts...)...,
+	)
+}
+
+// HandleIntent applies intents published with key. A player's intents must
+// be published under their own intents.<username> key, which the broker can
+// enforce with per-user topic permissions; anything claiming to be from
+// someone else is discarded.
+func HandleIntent(world *gamelogic.World, publisher pubsub.Publisher, names routing.Names) func(string, gamelogic.Intent) pubsub.AckType {
+	return func(key string, intent gamelogic.Intent) pubsub.AckType {
+		if key != routing.IntentKey(intent.Username) {
+			log.Printf("discarding %s for %q published as %s", intent.Kind, intent.Username, key)
+			return pubsub.NackDiscard
+		}
+
+		events, err := world.Apply(intent)
+		if err != nil {
+			log.Printf("rejected %s from %s: %v", intent.Kind, intent.Username, err)
+			events = []gamelogic.GameEvent{{
+				Kind:     gamelogic.EventRejected,
+				Username: intent.Username,
+				Reason:   err.Error(),
+			}}
+		}
+
+		// the world has already changed, so a failed broadcast is logged
+		// rather than requeued; replaying the intent would apply it twice
+		for _, ev := range events {
+			if err := PublishEvent(publisher, names, ev); err != nil {
+				log.Printf("could not broadcast %s event: %v", ev.Kind, err)
+			}
+		}
+		return pubsub.Ack
+	}
+}
+
+func PublishEvent(publisher pubsub.Publisher, names routing.Names, ev gamelogic.GameEvent) error {
+	return pubsub.PublishJSON(
+		publisher,
+		names.Topic,
+		routing.EventKey(ev.Username),
+		ev,
+	)
+}
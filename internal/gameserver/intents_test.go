@@ -0,0 +1,75 @@
+package gameserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestHandleIntent(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		intent     gamelogic.Intent
+		wantAck    pubsub.AckType
+		wantEvents []gamelogic.EventKind
+	}{
+		{
+			name:       "join",
+			key:        routing.IntentKey("alice"),
+			intent:     gamelogic.Intent{Kind: gamelogic.IntentJoin, Username: "alice"},
+			wantAck:    pubsub.Ack,
+			wantEvents: []gamelogic.EventKind{gamelogic.EventSync},
+		},
+		{
+			name:    "spoofed",
+			key:     routing.IntentKey("bob"),
+			intent:  gamelogic.Intent{Kind: gamelogic.IntentJoin, Username: "alice"},
+			wantAck: pubsub.NackDiscard,
+		},
+		{
+			name:       "rejected",
+			key:        routing.IntentKey("alice"),
+			intent:     gamelogic.Intent{Kind: gamelogic.IntentSpawn, Username: "alice", Rank: gamelogic.RankInfantry, Location: "europe"},
+			wantAck:    pubsub.Ack,
+			wantEvents: []gamelogic.EventKind{gamelogic.EventRejected},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pub := &recordingPublisher{}
+			handle := HandleIntent(gamelogic.NewWorld(gamelogic.DefaultBoard(), nil), pub, routing.DefaultNames())
+			if got := handle(tc.key, tc.intent); got != tc.wantAck {
+				t.Errorf("got %v, want %v", got, tc.wantAck)
+			}
+			if len(pub.events) != len(tc.wantEvents) {
+				t.Fatalf("published %d events, want %d", len(pub.events), len(tc.wantEvents))
+			}
+			for i, ev := range pub.events {
+				if ev.Kind != tc.wantEvents[i] || pub.keys[i] != routing.EventKey(tc.intent.Username) {
+					t.Errorf("event %d is %s with key %s, want %s with key %s", i, ev.Kind, pub.keys[i], tc.wantEvents[i], routing.EventKey(tc.intent.Username))
+				}
+			}
+		})
+	}
+}
+
+type recordingPublisher struct {
+	keys   []string
+	events []gamelogic.GameEvent
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, _, key string, msg pubsub.Message) error {
+	var ev gamelogic.GameEvent
+	if err := json.Unmarshal(msg.Body, &ev); err != nil {
+		return err
+	}
+	p.keys = append(p.keys, key)
+	p.events = append(p.events, ev)
+	return nil
+}
@@ -0,0 +1,96 @@
+package gameserver
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// pausePoll is how often a paused turn loop checks whether to carry on.
+const pausePoll = 500 * time.Millisecond
+
+// RunTurns opens a turn, collects orders until the deadline, resolves them
+// all at once and starts over until ctx is done. While the game is paused
+// no turn starts or ends, and the clock of a turn paused mid-way stops; its
+// deadline is announced again when play resumes.
+// Turns and events go out on the exchanges names gives. After every turn
+// the world is saved to savePath, unless it is empty.
+func RunTurns(ctx context.Context, world *gamelogic.World, publisher pubsub.Publisher, names routing.Names, length time.Duration, savePath string) {
+	for {
+		if !waitUnpaused(ctx, world) {
+			return
+		}
+		turn := world.StartTurn()
+		deadline := time.Now().Add(length)
+		log.Printf("turn %d started", turn)
+		publishTurn(publisher, names, routing.TurnState{Turn: turn, Phase: routing.TurnStart, Deadline: deadline})
+
+		if !waitTurn(ctx, world, publisher, names, turn, length) {
+			return
+		}
+
+		events := world.EndTurn()
+		log.Printf("turn %d ended with %d events", turn, len(events))
+		publishTurn(publisher, names, routing.TurnState{Turn: turn, Phase: routing.TurnEnd})
+		for _, ev := range events {
+			if err := PublishEvent(publisher, names, ev); err != nil {
+				log.Printf("could not broadcast %s event: %v", ev.Kind, err)
+			}
+		}
+		if savePath != "" {
+			if err := gamelogic.SaveWorld(world, savePath); err != nil {
+				log.Printf("could not save the world: %v", err)
+			}
+		}
+	}
+}
+
+// waitTurn lets length of unpaused time pass.
+func waitTurn(ctx context.Context, world *gamelogic.World, publisher pubsub.Publisher, names routing.Names, turn int, length time.Duration) bool {
+	remaining := length
+	last := time.Now()
+	paused := false
+	for remaining > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(min(remaining, pausePoll)):
+		}
+		now := time.Now()
+		wasPaused := paused
+		paused = world.Paused()
+		if !paused {
+			remaining -= now.Sub(last)
+		}
+		last = now
+		if wasPaused && !paused {
+			deadline := now.Add(max(remaining, 0))
+			log.Printf("turn %d resumed", turn)
+			publishTurn(publisher, names, routing.TurnState{Turn: turn, Phase: routing.TurnStart, Deadline: deadline})
+		}
+	}
+	return true
+}
+
+func waitUnpaused(ctx context.Context, world *gamelogic.World) bool {
+	ticker := time.NewTicker(pausePoll)
+	defer ticker.Stop()
+	for world.Paused() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return ctx.Err() == nil
+}
+
+func publishTurn(publisher pubsub.Publisher, names routing.Names, ts routing.TurnState) {
+	if err := pubsub.PublishJSON(publisher, names.Direct, routing.TurnKey, ts); err != nil {
+		log.Printf("could not broadcast turn %d %s: %v", ts.Turn, ts.Phase, err)
+	}
+}
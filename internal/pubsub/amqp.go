@@ -0,0 +1,363 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type amqpBroker struct {
+	conn *amqp.Connection
+}
+
+// NewAMQPBroker adapts an open RabbitMQ connection to the Broker interface.
+func NewAMQPBroker(conn *amqp.Connection) Broker {
+	return &amqpBroker{conn: conn}
+}
+
+// DialAMQP connects to RabbitMQ at url and returns it as a Broker.
+func DialAMQP(url string) (Broker, error) {
+	return DialAMQPWith(url, AMQPOptions{})
+}
+
+// AMQPOptions are how to reach a RabbitMQ server that wants more than the
+// credentials in its URL.
+type AMQPOptions struct {
+	// TLS is used for amqps URLs; nil means the system roots and no client
+	// certificate.
+	TLS *tls.Config
+	// External authenticates with the TLS client certificate (SASL
+	// EXTERNAL) instead of a username and password.
+	External bool
+	// Username and Password replace the matching credential in the URL if
+	// set; whichever one is left empty is taken from the URL.
+	Username string
+	Password string
+}
+
+// DialAMQPWith connects to RabbitMQ at rawURL with opts.
+func DialAMQPWith(rawURL string, opts AMQPOptions) (Broker, error) {
+	sasl, err := amqpAuth(rawURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := amqp.DialConfig(rawURL, amqp.Config{
+		TLSClientConfig: opts.TLS,
+		SASL:            sasl,
+		Locale:          "en_US",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewAMQPBroker(conn), nil
+}
+
+// amqpAuth picks how to log in: EXTERNAL if asked, PLAIN with opts'
+// credentials filled in from rawURL if either is set, or nil to let the
+// client use the URL's credentials as they are.
+func amqpAuth(rawURL string, opts AMQPOptions) ([]amqp.Authentication, error) {
+	if opts.External {
+		return []amqp.Authentication{&amqp.ExternalAuth{}}, nil
+	}
+	if opts.Username == "" && opts.Password == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad broker url: %v", err)
+	}
+	auth := &amqp.PlainAuth{Username: opts.Username, Password: opts.Password}
+	if auth.Username == "" {
+		auth.Username = u.User.Username()
+	}
+	if auth.Password == "" {
+		auth.Password, _ = u.User.Password()
+	}
+	return []amqp.Authentication{auth}, nil
+}
+
+// LoadTLSConfig builds a TLS config trusting the CA certificates in caFile,
+// or the system roots if it's empty, and presenting the certificate in
+// certFile and keyFile, if given.
+func LoadTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: serverName}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA file: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (b *amqpBroker) Channel() (Channel, error) {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return &amqpChannel{ch: ch, pending: map[string]bool{}, done: make(chan struct{})}, nil
+}
+
+func (b *amqpBroker) Close() error {
+	return b.conn.Close()
+}
+
+func (b *amqpBroker) NotifyClose() <-chan error {
+	amqpErrs := b.conn.NotifyClose(make(chan *amqp.Error, 1))
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		if err, ok := <-amqpErrs; ok && err != nil {
+			errs <- err
+		}
+	}()
+	return errs
+}
+
+type amqpChannel struct {
+	ch *amqp.Channel
+
+	// returns is only read by PublishConfirmed, under mu. pending holds
+	// the MessageId of every confirmed publish in flight, true once the
+	// broker has returned it.
+	returns chan amqp.Return
+	mu      sync.Mutex
+	pending map[string]bool
+	nextID  atomic.Uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *amqpChannel) ExchangeDeclare(name, kind string, durable bool) error {
+	return c.ch.ExchangeDeclare(name, kind, durable, false, false, false, nil)
+}
+
+func (c *amqpChannel) QueueDeclare(name string, durable, autoDelete, exclusive bool, args map[string]any) (Queue, error) {
+	q, err := c.ch.QueueDeclare(name, durable, autoDelete, exclusive, false, toTable(args))
+	if err != nil {
+		return Queue{}, err
+	}
+	return Queue{Name: q.Name}, nil
+}
+
+func (c *amqpChannel) QueueBind(queue, key, exchange string) error {
+	return c.ch.QueueBind(queue, key, exchange, false, nil)
+}
+
+func (c *amqpChannel) Qos(prefetch int) error {
+	return c.ch.Qos(prefetch, 0, false)
+}
+
+func (c *amqpChannel) Publish(ctx context.Context, exchange, key string, msg Message) error {
+	return c.ch.PublishWithContext(ctx, exchange, key, false, false, toPublishing(msg))
+}
+
+func (c *amqpChannel) Confirm() error {
+	if err := c.ch.Confirm(false); err != nil {
+		return err
+	}
+	c.returns = c.ch.NotifyReturn(make(chan amqp.Return, 64))
+	return nil
+}
+
+// PublishConfirmed tags the message with a MessageId so a basic.return can
+// be matched to it. RabbitMQ sends the return before the ack, and the
+// client library hands it to c.returns before resolving the confirmation,
+// so once the ack arrives any return for this message is waiting there.
+func (c *amqpChannel) PublishConfirmed(ctx context.Context, exchange, key string, msg Message) error {
+	pub := toPublishing(msg)
+	pub.MessageId = strconv.FormatUint(c.nextID.Add(1), 10)
+
+	c.mu.Lock()
+	c.pending[pub.MessageId] = false
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, pub.MessageId)
+		c.mu.Unlock()
+	}()
+
+	dc, err := c.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, true, false, pub)
+	if err != nil {
+		return err
+	}
+	acked, err := dc.WaitContext(ctx)
+	// drain even on failure, the library blocks while c.returns is full
+	returned := c.collectReturns(pub.MessageId)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrPublishNacked
+	}
+	if returned {
+		return ErrUnroutable
+	}
+	return nil
+}
+
+// collectReturns hands every waiting return to the publish it belongs to,
+// dropping returns for publishes nobody is waiting on any more, and reports
+// whether id was returned.
+func (c *amqpChannel) collectReturns(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		select {
+		case r := <-c.returns:
+			if _, ok := c.pending[r.MessageId]; ok {
+				c.pending[r.MessageId] = true
+			}
+		default:
+			return c.pending[id]
+		}
+	}
+}
+
+func (c *amqpChannel) Consume(queue string) (<-chan Delivery, error) {
+	msgs, err := c.ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not consume from %s: %v", queue, err)
+	}
+
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for msg := range msgs {
+			select {
+			case out <- fromDelivery(msg):
+			case <-c.done:
+				// unread deliveries are requeued by the broker when the
+				// channel closes
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// NotifyClose implements CloseNotifier for a channel the server closes,
+// e.g. after a channel exception. A cancelled consumer shows up as its
+// deliveries closing instead.
+func (c *amqpChannel) NotifyClose() <-chan error {
+	amqpErrs := c.ch.NotifyClose(make(chan *amqp.Error, 1))
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		if err, ok := <-amqpErrs; ok && err != nil {
+			errs <- err
+		}
+	}()
+	return errs
+}
+
+func (c *amqpChannel) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.ch.Close()
+}
+
+type amqpAcknowledger struct {
+	d amqp.Delivery
+}
+
+func (a amqpAcknowledger) Ack() error {
+	return a.d.Ack(false)
+}
+
+func (a amqpAcknowledger) Nack(requeue bool) error {
+	return a.d.Nack(false, requeue)
+}
+
+func toPublishing(msg Message) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType: msg.ContentType,
+		Headers:     toTable(msg.Headers),
+		Body:        msg.Body,
+	}
+}
+
+func fromDelivery(d amqp.Delivery) Delivery {
+	return Delivery{
+		Message: Message{
+			ContentType: d.ContentType,
+			Headers:     fromTable(d.Headers),
+			Body:        d.Body,
+		},
+		Exchange:     d.Exchange,
+		RoutingKey:   d.RoutingKey,
+		Redelivered:  d.Redelivered,
+		Acknowledger: amqpAcknowledger{d: d},
+	}
+}
+
+// fromTable turns amqp.Table values, including the nested tables found in
+// headers such as x-death, into plain maps.
+func fromTable(t amqp.Table) map[string]any {
+	if t == nil {
+		return nil
+	}
+	out := make(map[string]any, len(t))
+	for k, v := range t {
+		out[k] = fromTableValue(v)
+	}
+	return out
+}
+
+func fromTableValue(v any) any {
+	switch val := v.(type) {
+	case amqp.Table:
+		return fromTable(val)
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = fromTableValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+func toTable(m map[string]any) amqp.Table {
+	if m == nil {
+		return nil
+	}
+	out := make(amqp.Table, len(m))
+	for k, v := range m {
+		out[k] = toTableValue(v)
+	}
+	return out
+}
+
+func toTableValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return toTable(val)
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = toTableValue(item)
+		}
+		return out
+	}
+	return v
+}
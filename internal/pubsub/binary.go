@@ -0,0 +1,308 @@
+package pubsub
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// binaryCodec encodes values in a wire format modelled on protocol buffers,
+// without generated code. Struct fields are numbered by declaration order
+// starting at 1, so fields may be appended to a message type but never
+// reordered. Signed integers are always zigzag encoded, so the messages
+// can't be read as protobuf and carry their own content type.
+// Zero values are omitted, slices are repeated fields, maps are repeated
+// key (1) / value (2) entries, and types implementing
+// encoding.BinaryMarshaler (such as time.Time) are carried as bytes.
+// Non-struct values are wrapped as field 1 of an anonymous message.
+type binaryCodec struct{}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var (
+	binaryMarshalerType   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+	errTruncated          = errors.New("binary codec: truncated message")
+)
+
+func (binaryCodec) ContentType() string {
+	return ContentTypeBinary
+}
+
+func (binaryCodec) Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, errors.New("binary codec: cannot marshal nil")
+	}
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if isMessage(rv.Type()) {
+		return appendMessage(nil, rv)
+	}
+	return appendField(nil, 1, rv)
+}
+
+func (binaryCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("binary codec: Unmarshal needs a non-nil pointer")
+	}
+	rv = rv.Elem()
+	if isMessage(rv.Type()) {
+		return decodeMessage(data, rv)
+	}
+	return decodeFields(data, func(num int, wt int, raw []byte, n uint64) error {
+		if num != 1 {
+			return nil
+		}
+		return decodeValue(rv, wt, raw, n)
+	})
+}
+
+func isMessage(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !t.Implements(binaryMarshalerType)
+}
+
+func appendMessage(buf []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		var err error
+		buf, err = appendField(buf, i+1, v.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", t.Field(i).Name, err)
+		}
+	}
+	return buf, nil
+}
+
+// appendField writes v under field number num, omitting zero values and
+// expanding slices and maps into repeated entries.
+func appendField(buf []byte, num int, v reflect.Value) ([]byte, error) {
+	if v.IsZero() {
+		return buf, nil
+	}
+
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			if v.Index(i).Kind() == reflect.Slice && v.Index(i).Type().Elem().Kind() != reflect.Uint8 {
+				return nil, errors.New("nested slices are not supported")
+			}
+			var err error
+			if buf, err = appendValue(buf, num, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case v.Kind() == reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			entry, err := appendValue(nil, 1, iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			if entry, err = appendField(entry, 2, iter.Value()); err != nil {
+				return nil, err
+			}
+			buf = appendTag(buf, num, wireBytes)
+			buf = binary.AppendUvarint(buf, uint64(len(entry)))
+			buf = append(buf, entry...)
+		}
+		return buf, nil
+	}
+	return appendValue(buf, num, v)
+}
+
+// appendValue writes a single, non-repeated value including its tag.
+func appendValue(buf []byte, num int, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return buf, nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type().Implements(binaryMarshalerType) {
+		data, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(buf, num, data), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		var b uint64
+		if v.Bool() {
+			b = 1
+		}
+		return binary.AppendUvarint(appendTag(buf, num, wireVarint), b), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(appendTag(buf, num, wireVarint), v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.AppendUvarint(appendTag(buf, num, wireVarint), v.Uint()), nil
+	case reflect.Float32:
+		return binary.LittleEndian.AppendUint32(appendTag(buf, num, wireFixed32), math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.LittleEndian.AppendUint64(appendTag(buf, num, wireFixed64), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendBytes(buf, num, []byte(v.String())), nil
+	case reflect.Slice:
+		return appendBytes(buf, num, v.Bytes()), nil
+	case reflect.Struct:
+		msg, err := appendMessage(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(buf, num, msg), nil
+	}
+	return nil, fmt.Errorf("unsupported kind %s", v.Kind())
+}
+
+func appendTag(buf []byte, num, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(num)<<3|uint64(wireType))
+}
+
+func appendBytes(buf []byte, num int, data []byte) []byte {
+	buf = appendTag(buf, num, wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// decodeFields walks the fields of an encoded message. For length-delimited
+// fields raw holds the payload; for the numeric wire types n holds the value.
+func decodeFields(data []byte, fn func(num int, wireType int, raw []byte, n uint64) error) error {
+	for len(data) > 0 {
+		tag, size := binary.Uvarint(data)
+		if size <= 0 {
+			return errTruncated
+		}
+		data = data[size:]
+		num, wt := int(tag>>3), int(tag&7)
+
+		var raw []byte
+		var n uint64
+		switch wt {
+		case wireVarint:
+			n, size = binary.Uvarint(data)
+			if size <= 0 {
+				return errTruncated
+			}
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			n, size = binary.LittleEndian.Uint64(data), 8
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			n, size = uint64(binary.LittleEndian.Uint32(data)), 4
+		case wireBytes:
+			l, lsize := binary.Uvarint(data)
+			if lsize <= 0 || uint64(len(data)-lsize) < l {
+				return errTruncated
+			}
+			raw, size = data[lsize:lsize+int(l)], lsize+int(l)
+		default:
+			return fmt.Errorf("binary codec: unsupported wire type %d", wt)
+		}
+		data = data[size:]
+
+		if err := fn(num, wt, raw, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMessage(data []byte, v reflect.Value) error {
+	t := v.Type()
+	return decodeFields(data, func(num int, wt int, raw []byte, n uint64) error {
+		if num < 1 || num > t.NumField() || !t.Field(num-1).IsExported() {
+			return nil
+		}
+		if err := decodeValue(v.Field(num-1), wt, raw, n); err != nil {
+			return fmt.Errorf("field %s: %v", t.Field(num-1).Name, err)
+		}
+		return nil
+	})
+}
+
+// decodeValue stores one occurrence of a field into v, appending for
+// repeated fields and inserting for map entries.
+func decodeValue(v reflect.Value, wt int, raw []byte, n uint64) error {
+	if reflect.PointerTo(v.Type()).Implements(binaryUnmarshalerType) {
+		if wt != wireBytes {
+			return fmt.Errorf("unexpected wire type %d", wt)
+		}
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(raw)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeValue(v.Elem(), wt, raw, n)
+	case reflect.Bool:
+		v.SetBool(n != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x := int64(n>>1) ^ -int64(n&1)
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(n)
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(uint32(n))))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(n))
+	case reflect.String:
+		v.SetString(string(raw))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(append([]byte(nil), raw...))
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := decodeValue(elem, wt, raw, n); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		val := reflect.New(v.Type().Elem()).Elem()
+		err := decodeFields(raw, func(num int, wt int, raw []byte, n uint64) error {
+			switch num {
+			case 1:
+				return decodeValue(key, wt, raw, n)
+			case 2:
+				return decodeValue(val, wt, raw, n)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, val)
+	case reflect.Struct:
+		return decodeMessage(raw, v)
+	default:
+		return fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+)
+
+// Broker is a connection to a message broker. Everything in this package is
+// written against Broker and Channel so the game can run on top of RabbitMQ
+// or any other implementation of the two interfaces.
+type Broker interface {
+	Channel() (Channel, error)
+	Close() error
+}
+
+// Publisher sends a single message to an exchange.
+type Publisher interface {
+	Publish(ctx context.Context, exchange, key string, msg Message) error
+}
+
+// Channel is a unit of work on a Broker: topology declaration, publishing and
+// consuming all happen through a channel.
+type Channel interface {
+	Publisher
+	ExchangeDeclare(name, kind string, durable bool) error
+	QueueDeclare(name string, durable, autoDelete, exclusive bool, args map[string]any) (Queue, error)
+	QueueBind(queue, key, exchange string) error
+	// Qos limits how many deliveries may be unacknowledged on the channel at
+	// once. Zero means no limit.
+	Qos(prefetch int) error
+	Consume(queue string) (<-chan Delivery, error)
+	Close() error
+}
+
+type Queue struct {
+	Name string
+}
+
+type Message struct {
+	ContentType string
+	Headers     map[string]any
+	Body        []byte
+}
+
+// Acknowledger settles a delivery with the broker it came from.
+type Acknowledger interface {
+	Ack() error
+	Nack(requeue bool) error
+}
+
+type Delivery struct {
+	Message
+	Exchange     string
+	RoutingKey   string
+	Redelivered  bool
+	Acknowledger Acknowledger
+}
+
+var errNoAcknowledger = errors.New("delivery has no acknowledger")
+
+func (d Delivery) Ack() error {
+	if d.Acknowledger == nil {
+		return errNoAcknowledger
+	}
+	return d.Acknowledger.Ack()
+}
+
+func (d Delivery) Nack(requeue bool) error {
+	if d.Acknowledger == nil {
+		return errNoAcknowledger
+	}
+	return d.Acknowledger.Nack(requeue)
+}
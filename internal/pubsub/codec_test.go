@@ -0,0 +1,117 @@
+package pubsub_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	values := []struct {
+		name string
+		val  any
+		new  func() any
+	}{
+		{"game log", routing.GameLog{CurrentTime: now, Message: "war!", Username: "alice"}, func() any { return &routing.GameLog{} }},
+		{"turn state", routing.TurnState{Turn: 3, Phase: routing.TurnStart, Deadline: now}, func() any { return &routing.TurnState{} }},
+		{"intent", gamelogic.Intent{Kind: gamelogic.IntentMove, Username: "bob", Turn: 2, Location: "asia", UnitIDs: []int{1, 2, 3}}, func() any { return &gamelogic.Intent{} }},
+		{"negative int", -42, func() any { return new(int) }},
+		{"string", "hello", func() any { return new(string) }},
+		{"strings", []string{"a", "b"}, func() any { return new([]string) }},
+		{"map", map[string]int{"europe": 1, "asia": 2}, func() any { return new(map[string]int) }},
+	}
+
+	for _, codec := range []pubsub.Codec{pubsub.JSON, pubsub.Gob, pubsub.Binary} {
+		for _, v := range values {
+			t.Run(codec.ContentType()+"/"+v.name, func(t *testing.T) {
+				data, err := codec.Marshal(v.val)
+				if err != nil {
+					t.Fatalf("marshal: %v", err)
+				}
+				got := v.new()
+				if err := codec.Unmarshal(data, got); err != nil {
+					t.Fatalf("unmarshal: %v", err)
+				}
+				if !reflect.DeepEqual(reflect.ValueOf(got).Elem().Interface(), v.val) {
+					t.Errorf("got %+v, want %+v", reflect.ValueOf(got).Elem().Interface(), v.val)
+				}
+			})
+		}
+	}
+}
+
+func TestBinaryWireFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		val  any
+		want []byte
+	}{
+		{"zigzag int", struct{ N int }{75}, []byte{0x08, 0x96, 0x01}},
+		{"uint", struct{ N uint }{150}, []byte{0x08, 0x96, 0x01}},
+		{"zero values omitted", struct {
+			A int
+			B string
+		}{0, "hi"}, []byte{0x12, 0x02, 'h', 'i'}},
+		{"bool", struct{ B bool }{true}, []byte{0x08, 0x01}},
+		{"bare value is field 1", "hi", []byte{0x0a, 0x02, 'h', 'i'}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := pubsub.Binary.Marshal(tc.val)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("got % x, want % x", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBinaryUnmarshalErrors(t *testing.T) {
+	var s struct{ Name string }
+	if err := pubsub.Binary.Unmarshal([]byte{0x0a, 0x05, 'h'}, &s); err == nil {
+		t.Error("truncated message decoded")
+	}
+	if err := pubsub.Binary.Unmarshal([]byte{0x0a, 0x00}, s); err == nil {
+		t.Error("decoded into a non-pointer")
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	for _, codec := range []pubsub.Codec{pubsub.JSON, pubsub.Gob, pubsub.Binary} {
+		got, err := pubsub.CodecFor(codec.ContentType())
+		if err != nil || got != codec {
+			t.Errorf("CodecFor(%s) = %v, %v", codec.ContentType(), got, err)
+		}
+	}
+	if _, err := pubsub.CodecFor("text/plain"); err == nil {
+		t.Error("found a codec for an unregistered content type")
+	}
+
+	pubsub.RegisterCodec(upperCodec{})
+	if got, err := pubsub.CodecFor(upperCodec{}.ContentType()); err != nil || got != (upperCodec{}) {
+		t.Errorf("registered codec not found: %v, %v", got, err)
+	}
+}
+
+// upperCodec is a toy codec for plain text.
+type upperCodec struct{}
+
+func (upperCodec) ContentType() string { return "text/x-upper" }
+
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	return bytes.ToUpper([]byte(v.(string))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	*v.(*string) = string(data)
+	return nil
+}
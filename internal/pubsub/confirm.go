@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnroutable     = errors.New("message could not be routed to any queue")
+	ErrPublishNacked  = errors.New("broker refused the message")
+	ErrConfirmTimeout = errors.New("timed out waiting for publisher confirm")
+)
+
+// PublishError is returned by ConfirmingPublisher when a message was not
+// accepted by the broker. Err is one of ErrUnroutable, ErrPublishNacked,
+// ErrConfirmTimeout or the underlying transport error.
+type PublishError struct {
+	Exchange string
+	Key      string
+	Err      error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("publish to %s with key %s: %v", e.Exchange, e.Key, e.Err)
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// ConfirmChannel is a Channel that supports publisher confirms.
+// PublishConfirmed publishes with the mandatory flag set and blocks until
+// the broker acks, nacks or returns the message.
+type ConfirmChannel interface {
+	Channel
+	Confirm() error
+	PublishConfirmed(ctx context.Context, exchange, key string, msg Message) error
+}
+
+type ConfirmingPublisher struct {
+	ch      ConfirmChannel
+	timeout time.Duration
+}
+
+// NewConfirmingPublisher puts ch into confirm mode. Each Publish waits up to
+// timeout for the broker's answer.
+func NewConfirmingPublisher(ch Channel, timeout time.Duration) (*ConfirmingPublisher, error) {
+	cc, ok := ch.(ConfirmChannel)
+	if !ok {
+		return nil, errors.New("channel does not support publisher confirms")
+	}
+	if err := cc.Confirm(); err != nil {
+		return nil, fmt.Errorf("couldn't enable confirm mode: %v", err)
+	}
+	return &ConfirmingPublisher{ch: cc, timeout: timeout}, nil
+}
+
+func (p *ConfirmingPublisher) Publish(ctx context.Context, exchange, key string, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	err := p.ch.PublishConfirmed(ctx, exchange, key, msg)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = ErrConfirmTimeout
+	}
+	return &PublishError{Exchange: exchange, Key: key, Err: err}
+}
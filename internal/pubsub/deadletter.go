@@ -0,0 +1,61 @@
+package pubsub
+
+import (
+	"time"
+)
+
+// Death is one entry of the x-death header the broker adds each time a
+// message is dead-lettered.
+type Death struct {
+	Queue       string
+	Reason      string
+	Exchange    string
+	RoutingKeys []string
+	Count       int64
+	Time        time.Time
+}
+
+// Deaths parses the x-death header, most recent entry first.
+func Deaths(headers map[string]any) []Death {
+	entries, _ := headers["x-death"].([]any)
+	deaths := make([]Death, 0, len(entries))
+	for _, e := range entries {
+		table, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		d := Death{}
+		d.Queue, _ = table["queue"].(string)
+		d.Reason, _ = table["reason"].(string)
+		d.Exchange, _ = table["exchange"].(string)
+		d.Count, _ = table["count"].(int64)
+		d.Time, _ = table["time"].(time.Time)
+		keys, _ := table["routing-keys"].([]any)
+		for _, k := range keys {
+			if key, ok := k.(string); ok {
+				d.RoutingKeys = append(d.RoutingKeys, key)
+			}
+		}
+		deaths = append(deaths, d)
+	}
+	return deaths
+}
+
+// OriginalRoute returns the exchange and routing key a dead-lettered message
+// was first published with, looking through any retry queues it visited.
+func OriginalRoute(headers map[string]any) (exchange, key string, ok bool) {
+	if exchange, ok := headers[HeaderOriginalExchange].(string); ok {
+		key, _ := headers[HeaderOriginalKey].(string)
+		return exchange, key, true
+	}
+
+	deaths := Deaths(headers)
+	if len(deaths) == 0 {
+		return "", "", false
+	}
+	first := deaths[len(deaths)-1]
+	if len(first.RoutingKeys) == 0 {
+		return "", "", false
+	}
+	return first.Exchange, first.RoutingKeys[0], true
+}
@@ -0,0 +1,91 @@
+package pubsub_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+func TestDeaths(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	headers := map[string]any{"x-death": []any{
+		map[string]any{
+			"queue":        "logs.retry.1s",
+			"reason":       "expired",
+			"exchange":     "",
+			"routing-keys": []any{"logs.retry.1s"},
+			"count":        int64(2),
+			"time":         at,
+		},
+		"not a table",
+		map[string]any{
+			"queue":        "logs",
+			"reason":       "rejected",
+			"exchange":     "peril_topic",
+			"routing-keys": []any{"game_logs.alice", 7},
+			"count":        int64(1),
+		},
+	}}
+
+	want := []pubsub.Death{
+		{Queue: "logs.retry.1s", Reason: "expired", RoutingKeys: []string{"logs.retry.1s"}, Count: 2, Time: at},
+		{Queue: "logs", Reason: "rejected", Exchange: "peril_topic", RoutingKeys: []string{"game_logs.alice"}, Count: 1},
+	}
+	if got := pubsub.Deaths(headers); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := pubsub.Deaths(nil); len(got) != 0 {
+		t.Errorf("got %+v from no headers", got)
+	}
+}
+
+func TestOriginalRoute(t *testing.T) {
+	death := func(exchange string, keys ...any) map[string]any {
+		return map[string]any{"exchange": exchange, "routing-keys": keys}
+	}
+	tests := []struct {
+		name         string
+		headers      map[string]any
+		wantExchange string
+		wantKey      string
+		wantOK       bool
+	}{
+		{
+			name:    "no headers",
+			headers: nil,
+		},
+		{
+			name:         "first death is the original",
+			headers:      map[string]any{"x-death": []any{death("", "logs"), death("peril_topic", "game_logs.alice")}},
+			wantExchange: "peril_topic",
+			wantKey:      "game_logs.alice",
+			wantOK:       true,
+		},
+		{
+			name: "retry headers win",
+			headers: map[string]any{
+				pubsub.HeaderOriginalExchange: "peril_topic",
+				pubsub.HeaderOriginalKey:      "game_logs.bob",
+				"x-death":                     []any{death("", "logs")},
+			},
+			wantExchange: "peril_topic",
+			wantKey:      "game_logs.bob",
+			wantOK:       true,
+		},
+		{
+			name:    "death without keys",
+			headers: map[string]any{"x-death": []any{death("peril_topic")}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			exchange, key, ok := pubsub.OriginalRoute(tc.headers)
+			if exchange != tc.wantExchange || key != tc.wantKey || ok != tc.wantOK {
+				t.Errorf("got %q %q %v, want %q %q %v", exchange, key, ok, tc.wantExchange, tc.wantKey, tc.wantOK)
+			}
+		})
+	}
+}
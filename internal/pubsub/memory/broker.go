@@ -0,0 +1,304 @@
+// Package memory is an in-process implementation of pubsub.Broker. It
+// emulates the parts of RabbitMQ that Peril relies on so that whole games can
+// be simulated inside a single go test binary.
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var (
+	ErrClosed    = errors.New("memory broker: channel or connection is closed")
+	ErrRestarted = errors.New("memory broker: connection closed by broker restart")
+)
+
+// Broker holds the exchanges and queues shared by every connection made with
+// Connect. The Peril exchanges are declared up front, matching a freshly
+// configured RabbitMQ.
+type Broker struct {
+	mu        sync.Mutex
+	exchanges map[string]*exchange
+	queues    map[string]*queue
+	conns     map[*Conn]struct{}
+	changed   chan struct{}
+	nextQueue int
+	nextTag   uint64
+}
+
+type exchange struct {
+	name     string
+	kind     string
+	durable  bool
+	bindings []binding
+}
+
+type binding struct {
+	queue string
+	key   string
+}
+
+type queue struct {
+	name       string
+	durable    bool
+	autoDelete bool
+	exclusive  bool
+	owner      *Conn
+	args       map[string]any
+	messages   []*message
+	consumers  int
+}
+
+type message struct {
+	msg         pubsub.Message
+	exchange    string
+	key         string
+	redelivered bool
+}
+
+func NewBroker() *Broker {
+	b := &Broker{
+		exchanges: map[string]*exchange{},
+		queues:    map[string]*queue{},
+		conns:     map[*Conn]struct{}{},
+		changed:   make(chan struct{}),
+	}
+	b.exchanges[""] = &exchange{kind: routing.KindDirect, durable: true}
+	b.exchanges[routing.ExchangePerilDirect] = &exchange{name: routing.ExchangePerilDirect, kind: routing.KindDirect, durable: true}
+	b.exchanges[routing.ExchangePerilTopic] = &exchange{name: routing.ExchangePerilTopic, kind: routing.KindTopic, durable: true}
+	b.exchanges[routing.ExchangePerilDLX] = &exchange{name: routing.ExchangePerilDLX, kind: routing.KindFanout, durable: true}
+	return b
+}
+
+// Connect opens a new connection. Exclusive queues are owned by the
+// connection that declared them and are deleted when it closes.
+func (b *Broker) Connect() *Conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := &Conn{b: b, channels: map[*Channel]struct{}{}}
+	b.conns[c] = struct{}{}
+	return c
+}
+
+// Dial connects to the broker; it satisfies pubsub.Dialer so the memory
+// broker can sit behind a pubsub.ManagedBroker.
+func (b *Broker) Dial() (pubsub.Broker, error) {
+	return b.Connect(), nil
+}
+
+// Restart simulates a broker restart: every connection is dropped, transient
+// queues and exchanges disappear and durable queues keep their messages.
+func (b *Broker) Restart() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		for _, errs := range c.notify {
+			errs <- ErrRestarted
+		}
+		c.closeLocked()
+	}
+	for name, q := range b.queues {
+		if !q.durable {
+			b.deleteQueueLocked(name)
+		}
+	}
+	for name, ex := range b.exchanges {
+		if !ex.durable {
+			delete(b.exchanges, name)
+		}
+	}
+}
+
+// QueueLength reports the number of ready (not yet delivered) messages.
+func (b *Broker) QueueLength(name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return 0
+	}
+	return len(q.messages)
+}
+
+// notifyLocked wakes every consumer waiting for a state change.
+func (b *Broker) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *Broker) publishLocked(exchangeName, key string, msg pubsub.Message) (int, error) {
+	ex, ok := b.exchanges[exchangeName]
+	if !ok {
+		return 0, fmt.Errorf("no exchange '%s'", exchangeName)
+	}
+
+	var targets []*queue
+	if exchangeName == "" {
+		if q, ok := b.queues[key]; ok {
+			targets = append(targets, q)
+		}
+	} else {
+		seen := map[string]bool{}
+		for _, bd := range ex.bindings {
+			if seen[bd.queue] || !ex.matches(bd.key, key) {
+				continue
+			}
+			seen[bd.queue] = true
+			targets = append(targets, b.queues[bd.queue])
+		}
+	}
+
+	for _, q := range targets {
+		m := &message{
+			msg:      copyMessage(msg),
+			exchange: exchangeName,
+			key:      key,
+		}
+		q.messages = append(q.messages, m)
+		if ttl, ok := toMillis(q.args["x-message-ttl"]); ok {
+			time.AfterFunc(ttl, func() { b.expire(q, m) })
+		}
+	}
+	if len(targets) > 0 {
+		b.notifyLocked()
+	}
+	return len(targets), nil
+}
+
+// expire dead-letters m if it is still waiting in q once its TTL is up.
+func (b *Broker) expire(q *queue, m *message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, queued := range q.messages {
+		if queued == m {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			b.deadLetterLocked(q, m, "expired")
+			return
+		}
+	}
+}
+
+func toMillis(v any) (time.Duration, bool) {
+	switch n := v.(type) {
+	case int:
+		return time.Duration(n) * time.Millisecond, true
+	case int32:
+		return time.Duration(n) * time.Millisecond, true
+	case int64:
+		return time.Duration(n) * time.Millisecond, true
+	}
+	return 0, false
+}
+
+func (ex *exchange) matches(bindingKey, routingKey string) bool {
+	switch ex.kind {
+	case routing.KindFanout:
+		return true
+	case routing.KindTopic:
+		return topicMatch(bindingKey, routingKey)
+	}
+	return bindingKey == routingKey
+}
+
+// deadLetterLocked republishes a rejected message to the queue's
+// x-dead-letter-exchange, recording the hop in the x-death header the same
+// way RabbitMQ does.
+func (b *Broker) deadLetterLocked(q *queue, m *message, reason string) {
+	dlx, ok := q.args[routing.ArgDeadLetterExchange].(string)
+	if !ok {
+		return
+	}
+	key := m.key
+	if k, ok := q.args["x-dead-letter-routing-key"].(string); ok {
+		key = k
+	}
+
+	msg := copyMessage(m.msg)
+	if msg.Headers == nil {
+		msg.Headers = map[string]any{}
+	}
+	msg.Headers["x-death"] = addDeath(msg.Headers["x-death"], q.name, reason, m.exchange, m.key)
+	if _, ok := msg.Headers["x-first-death-queue"]; !ok {
+		msg.Headers["x-first-death-queue"] = q.name
+		msg.Headers["x-first-death-reason"] = reason
+		msg.Headers["x-first-death-exchange"] = m.exchange
+	}
+	b.publishLocked(dlx, key, msg)
+}
+
+func addDeath(existing any, queueName, reason, exchangeName, key string) []any {
+	deaths, _ := existing.([]any)
+	for i, d := range deaths {
+		entry, ok := d.(map[string]any)
+		if !ok || entry["queue"] != queueName || entry["reason"] != reason {
+			continue
+		}
+		updated := map[string]any{}
+		for k, v := range entry {
+			updated[k] = v
+		}
+		count, _ := updated["count"].(int64)
+		updated["count"] = count + 1
+		updated["time"] = time.Now()
+		rest := append([]any{}, deaths[:i]...)
+		rest = append(rest, deaths[i+1:]...)
+		return append([]any{updated}, rest...)
+	}
+	return append([]any{map[string]any{
+		"count":        int64(1),
+		"reason":       reason,
+		"queue":        queueName,
+		"exchange":     exchangeName,
+		"routing-keys": []any{key},
+		"time":         time.Now(),
+	}}, deaths...)
+}
+
+// DeleteQueue deletes a queue the way an operator would, cancelling its
+// consumers.
+func (b *Broker) DeleteQueue(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return
+	}
+	for c := range b.conns {
+		for ch := range c.channels {
+			ch.cancelConsumersLocked(q)
+		}
+	}
+	b.deleteQueueLocked(name)
+}
+
+func (b *Broker) deleteQueueLocked(name string) {
+	delete(b.queues, name)
+	for _, ex := range b.exchanges {
+		kept := ex.bindings[:0]
+		for _, bd := range ex.bindings {
+			if bd.queue != name {
+				kept = append(kept, bd)
+			}
+		}
+		ex.bindings = kept
+	}
+}
+
+func copyMessage(msg pubsub.Message) pubsub.Message {
+	out := msg
+	if msg.Headers != nil {
+		out.Headers = make(map[string]any, len(msg.Headers))
+		for k, v := range msg.Headers {
+			out.Headers[k] = v
+		}
+	}
+	if msg.Body != nil {
+		out.Body = append([]byte(nil), msg.Body...)
+	}
+	return out
+}
@@ -0,0 +1,336 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const receiveTimeout = time.Second
+
+func TestRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange string
+		binding  string
+		key      string
+		want     int
+	}{
+		{"direct match", routing.ExchangePerilDirect, routing.PauseKey, routing.PauseKey, 1},
+		{"direct mismatch", routing.ExchangePerilDirect, routing.PauseKey, routing.TurnKey, 0},
+		{"topic wildcard", routing.ExchangePerilTopic, routing.AllKeys(routing.EventsPrefix), routing.EventKey("alice"), 1},
+		{"topic other prefix", routing.ExchangePerilTopic, routing.AllKeys(routing.EventsPrefix), routing.IntentKey("alice"), 0},
+		{"topic hash", routing.ExchangePerilTopic, "#", routing.GameLogKey("alice"), 1},
+		{"fanout ignores key", routing.ExchangePerilDLX, "", "anything", 1},
+		{"default exchange by queue name", "", "", "q", 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBroker()
+			ch := channel(t, b.Connect())
+			declare(t, ch, "q", nil)
+			if tc.exchange != "" {
+				if err := ch.QueueBind("q", tc.binding, tc.exchange); err != nil {
+					t.Fatalf("bind: %v", err)
+				}
+			}
+			publish(t, ch, tc.exchange, tc.key, "hello")
+			if got := b.QueueLength("q"); got != tc.want {
+				t.Errorf("queue has %d messages, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name          string
+		settle        func(pubsub.Delivery) error
+		wantDead      int
+		wantRedeliver bool
+	}{
+		{"ack", func(d pubsub.Delivery) error { return d.Ack() }, 0, false},
+		{"nack requeue", func(d pubsub.Delivery) error { return d.Nack(true) }, 0, true},
+		{"nack discard", func(d pubsub.Delivery) error { return d.Nack(false) }, 1, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBroker()
+			ch := channel(t, b.Connect())
+			deadLetterQueue(t, ch)
+			declare(t, ch, "work", map[string]any{routing.ArgDeadLetterExchange: routing.ExchangePerilDLX})
+			publish(t, ch, "", "work", "job")
+
+			consumer := channel(t, b.Connect())
+			msgs, err := consumer.Consume("work")
+			if err != nil {
+				t.Fatalf("consume: %v", err)
+			}
+			d := receive(t, msgs)
+			if err := tc.settle(d); err != nil {
+				t.Fatalf("settle: %v", err)
+			}
+			if err := d.Ack(); err == nil {
+				t.Error("settling a delivery twice succeeded")
+			}
+
+			if tc.wantRedeliver {
+				again := receive(t, msgs)
+				if !again.Redelivered {
+					t.Error("requeued delivery isn't marked redelivered")
+				}
+				if err := again.Ack(); err != nil {
+					t.Fatalf("ack: %v", err)
+				}
+			}
+			consumer.Close()
+			if got := b.QueueLength("work"); got != 0 {
+				t.Errorf("work queue has %d messages left, want 0", got)
+			}
+			if got := b.QueueLength(routing.DeadLetterQueue); got != tc.wantDead {
+				t.Errorf("dead letter queue has %d messages, want %d", got, tc.wantDead)
+			}
+		})
+	}
+}
+
+func TestDeadLetterHeaders(t *testing.T) {
+	b := NewBroker()
+	ch := channel(t, b.Connect())
+	deadLetterQueue(t, ch)
+	declare(t, ch, "work", map[string]any{routing.ArgDeadLetterExchange: routing.ExchangePerilDLX})
+	if err := ch.QueueBind("work", routing.AllKeys(routing.EventsPrefix), routing.ExchangePerilTopic); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	publish(t, ch, routing.ExchangePerilTopic, routing.EventKey("alice"), "event")
+
+	msgs, err := ch.Consume("work")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if err := receive(t, msgs).Nack(false); err != nil {
+		t.Fatalf("nack: %v", err)
+	}
+
+	dead, err := ch.Consume(routing.DeadLetterQueue)
+	if err != nil {
+		t.Fatalf("consume dead letters: %v", err)
+	}
+	d := receive(t, dead)
+	deaths := pubsub.Deaths(d.Headers)
+	if len(deaths) != 1 {
+		t.Fatalf("got %d deaths, want 1", len(deaths))
+	}
+	if deaths[0].Queue != "work" || deaths[0].Reason != "rejected" || deaths[0].Count != 1 {
+		t.Errorf("got death %+v, want one rejection from work", deaths[0])
+	}
+	exchange, key, ok := pubsub.OriginalRoute(d.Headers)
+	if !ok || exchange != routing.ExchangePerilTopic || key != routing.EventKey("alice") {
+		t.Errorf("got original route %q %q %v, want %s %s", exchange, key, ok, routing.ExchangePerilTopic, routing.EventKey("alice"))
+	}
+}
+
+func TestMessageTTL(t *testing.T) {
+	b := NewBroker()
+	ch := channel(t, b.Connect())
+	declare(t, ch, "target", nil)
+	declare(t, ch, "parked", map[string]any{
+		"x-message-ttl":               int64(10),
+		routing.ArgDeadLetterExchange: "",
+		"x-dead-letter-routing-key":   "target",
+	})
+	publish(t, ch, "", "parked", "later")
+
+	msgs, err := ch.Consume("target")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	d := receive(t, msgs)
+	if string(d.Body) != "later" {
+		t.Errorf("got %q, want the parked message", d.Body)
+	}
+	if got := b.QueueLength("parked"); got != 0 {
+		t.Errorf("parked queue still has %d messages", got)
+	}
+}
+
+func TestCloseRequeuesUnacked(t *testing.T) {
+	b := NewBroker()
+	ch := channel(t, b.Connect())
+	declare(t, ch, "work", nil)
+	publish(t, ch, "", "work", "one")
+	publish(t, ch, "", "work", "two")
+
+	consumer := channel(t, b.Connect())
+	msgs, err := consumer.Consume("work")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	receive(t, msgs)
+	consumer.Close()
+
+	msgs, err = ch.Consume("work")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	first := receive(t, msgs)
+	if string(first.Body) != "one" || !first.Redelivered {
+		t.Errorf("got %q (redelivered %v), want one redelivered first", first.Body, first.Redelivered)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	b := NewBroker()
+	ch := channel(t, b.Connect())
+	declare(t, ch, "work", nil)
+	for range 3 {
+		publish(t, ch, "", "work", "job")
+	}
+
+	if err := ch.Qos(1); err != nil {
+		t.Fatalf("qos: %v", err)
+	}
+	msgs, err := ch.Consume("work")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	d := receive(t, msgs)
+	select {
+	case <-msgs:
+		t.Fatal("got a second delivery past the prefetch limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := d.Ack(); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	receive(t, msgs)
+}
+
+func TestExclusiveQueue(t *testing.T) {
+	b := NewBroker()
+	owner := b.Connect()
+	ch := channel(t, owner)
+	if _, err := ch.QueueDeclare("mine", false, true, true, nil); err != nil {
+		t.Fatalf("declare: %v", err)
+	}
+
+	other := channel(t, b.Connect())
+	if _, err := other.QueueDeclare("mine", false, true, true, nil); err == nil {
+		t.Error("another connection declared an exclusive queue")
+	}
+	if _, err := other.Consume("mine"); err == nil {
+		t.Error("another connection consumed from an exclusive queue")
+	}
+
+	owner.Close()
+	if _, err := other.QueueDeclare("mine", false, true, true, nil); err != nil {
+		t.Errorf("queue wasn't freed when its owner closed: %v", err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	b := NewBroker()
+	conn := b.Connect()
+	errs := conn.NotifyClose()
+	ch := channel(t, conn)
+	if _, err := ch.QueueDeclare("durable", true, false, false, nil); err != nil {
+		t.Fatalf("declare: %v", err)
+	}
+	if _, err := ch.QueueDeclare("transient", false, false, false, nil); err != nil {
+		t.Fatalf("declare: %v", err)
+	}
+	publish(t, ch, "", "durable", "kept")
+	publish(t, ch, "", "transient", "lost")
+
+	b.Restart()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrRestarted) {
+			t.Errorf("got close error %v, want ErrRestarted", err)
+		}
+	case <-time.After(receiveTimeout):
+		t.Fatal("connection wasn't told about the restart")
+	}
+	if err := ch.Publish(context.Background(), "", "durable", pubsub.Message{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("publishing on a dropped channel: got %v, want ErrClosed", err)
+	}
+	if got := b.QueueLength("durable"); got != 1 {
+		t.Errorf("durable queue has %d messages, want 1", got)
+	}
+	if got := b.QueueLength("transient"); got != 0 {
+		t.Errorf("transient queue has %d messages, want 0", got)
+	}
+}
+
+func TestPublishConfirmed(t *testing.T) {
+	b := NewBroker()
+	ch := channel(t, b.Connect()).(*Channel)
+	declare(t, ch, "q", nil)
+
+	if err := ch.PublishConfirmed(context.Background(), "", "q", pubsub.Message{}); err == nil {
+		t.Error("confirmed publish succeeded outside confirm mode")
+	}
+	if err := ch.Confirm(); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if err := ch.PublishConfirmed(context.Background(), "", "q", pubsub.Message{}); err != nil {
+		t.Errorf("routed publish: %v", err)
+	}
+	if err := ch.PublishConfirmed(context.Background(), "", "nowhere", pubsub.Message{}); !errors.Is(err, pubsub.ErrUnroutable) {
+		t.Errorf("unroutable publish: got %v, want ErrUnroutable", err)
+	}
+	if err := ch.PublishConfirmed(context.Background(), "missing", "q", pubsub.Message{}); err == nil {
+		t.Error("publish to a missing exchange succeeded")
+	}
+}
+
+func channel(t *testing.T, conn *Conn) pubsub.Channel {
+	t.Helper()
+	ch, err := conn.Channel()
+	if err != nil {
+		t.Fatalf("channel: %v", err)
+	}
+	return ch
+}
+
+func declare(t *testing.T, ch pubsub.Channel, name string, args map[string]any) {
+	t.Helper()
+	if _, err := ch.QueueDeclare(name, true, false, false, args); err != nil {
+		t.Fatalf("declare %s: %v", name, err)
+	}
+}
+
+func deadLetterQueue(t *testing.T, ch pubsub.Channel) {
+	t.Helper()
+	declare(t, ch, routing.DeadLetterQueue, nil)
+	if err := ch.QueueBind(routing.DeadLetterQueue, "", routing.ExchangePerilDLX); err != nil {
+		t.Fatalf("bind dead letter queue: %v", err)
+	}
+}
+
+func publish(t *testing.T, ch pubsub.Channel, exchange, key, body string) {
+	t.Helper()
+	if err := ch.Publish(context.Background(), exchange, key, pubsub.Message{Body: []byte(body)}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+}
+
+func receive(t *testing.T, msgs <-chan pubsub.Delivery) pubsub.Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-msgs:
+		if !ok {
+			t.Fatal("deliveries closed")
+		}
+		return d
+	case <-time.After(receiveTimeout):
+		t.Fatal("no delivery")
+	}
+	return pubsub.Delivery{}
+}
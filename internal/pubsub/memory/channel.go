@@ -0,0 +1,419 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Conn is a single client connection to a Broker. It implements
+// pubsub.Broker.
+type Conn struct {
+	b        *Broker
+	channels map[*Channel]struct{}
+	notify   []chan error
+	closed   bool
+}
+
+func (c *Conn) Channel() (pubsub.Channel, error) {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	ch := &Channel{conn: c, unacked: map[uint64]*pending{}}
+	c.channels[ch] = struct{}{}
+	return ch, nil
+}
+
+func (c *Conn) Close() error {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	c.closeLocked()
+	return nil
+}
+
+// NotifyClose implements pubsub.CloseNotifier.
+func (c *Conn) NotifyClose() <-chan error {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	errs := make(chan error, 1)
+	if c.closed {
+		close(errs)
+		return errs
+	}
+	c.notify = append(c.notify, errs)
+	return errs
+}
+
+func (c *Conn) closeLocked() {
+	c.closed = true
+	for _, errs := range c.notify {
+		close(errs)
+	}
+	c.notify = nil
+	for ch := range c.channels {
+		ch.closeLocked()
+	}
+	for name, q := range c.b.queues {
+		if q.exclusive && q.owner == c {
+			c.b.deleteQueueLocked(name)
+		}
+	}
+	delete(c.b.conns, c)
+	c.b.notifyLocked()
+}
+
+// Channel implements pubsub.Channel. Messages delivered on a channel stay
+// unacknowledged until settled and are requeued if the channel closes first.
+type Channel struct {
+	conn      *Conn
+	consumers []*consumer
+	unacked   map[uint64]*pending
+	prefetch  int
+	confirm   bool
+	closed    bool
+}
+
+type pending struct {
+	q *queue
+	m *message
+}
+
+func (ch *Channel) broker() *Broker {
+	return ch.conn.b
+}
+
+func (ch *Channel) ExchangeDeclare(name, kind string, durable bool) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return ErrClosed
+	}
+
+	if ex, ok := b.exchanges[name]; ok {
+		if ex.kind != kind || ex.durable != durable {
+			return fmt.Errorf("inequivalent arg for exchange '%s'", name)
+		}
+		return nil
+	}
+	switch kind {
+	case routing.KindDirect, routing.KindTopic, routing.KindFanout:
+	default:
+		return fmt.Errorf("unsupported exchange kind '%s'", kind)
+	}
+	b.exchanges[name] = &exchange{name: name, kind: kind, durable: durable}
+	return nil
+}
+
+func (ch *Channel) QueueDeclare(name string, durable, autoDelete, exclusive bool, args map[string]any) (pubsub.Queue, error) {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return pubsub.Queue{}, ErrClosed
+	}
+
+	if name == "" {
+		b.nextQueue++
+		name = fmt.Sprintf("amq.gen-%d", b.nextQueue)
+	}
+
+	if q, ok := b.queues[name]; ok {
+		if q.exclusive && q.owner != ch.conn {
+			return pubsub.Queue{}, fmt.Errorf("cannot obtain exclusive access to locked queue '%s'", name)
+		}
+		if q.durable != durable || q.autoDelete != autoDelete || q.exclusive != exclusive {
+			return pubsub.Queue{}, fmt.Errorf("inequivalent arg for queue '%s'", name)
+		}
+		return pubsub.Queue{Name: name}, nil
+	}
+
+	b.queues[name] = &queue{
+		name:       name,
+		durable:    durable,
+		autoDelete: autoDelete,
+		exclusive:  exclusive,
+		owner:      ch.conn,
+		args:       args,
+	}
+	return pubsub.Queue{Name: name}, nil
+}
+
+func (ch *Channel) QueueBind(queueName, key, exchangeName string) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return ErrClosed
+	}
+
+	ex, ok := b.exchanges[exchangeName]
+	if !ok || exchangeName == "" {
+		return fmt.Errorf("no exchange '%s'", exchangeName)
+	}
+	if _, ok := b.queues[queueName]; !ok {
+		return fmt.Errorf("no queue '%s'", queueName)
+	}
+	for _, bd := range ex.bindings {
+		if bd.queue == queueName && bd.key == key {
+			return nil
+		}
+	}
+	ex.bindings = append(ex.bindings, binding{queue: queueName, key: key})
+	return nil
+}
+
+func (ch *Channel) Qos(prefetch int) error {
+	if prefetch < 0 {
+		return fmt.Errorf("invalid prefetch count %d", prefetch)
+	}
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return ErrClosed
+	}
+	ch.prefetch = prefetch
+	b.notifyLocked()
+	return nil
+}
+
+func (ch *Channel) Publish(ctx context.Context, exchangeName, key string, msg pubsub.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return ErrClosed
+	}
+	_, err := b.publishLocked(exchangeName, key, msg)
+	return err
+}
+
+func (ch *Channel) Confirm() error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return ErrClosed
+	}
+	ch.confirm = true
+	return nil
+}
+
+// PublishConfirmed routes the message synchronously, so the confirm is
+// immediate: an ack if at least one queue took the message and
+// pubsub.ErrUnroutable otherwise.
+func (ch *Channel) PublishConfirmed(ctx context.Context, exchangeName, key string, msg pubsub.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return ErrClosed
+	}
+	if !ch.confirm {
+		return errors.New("channel is not in confirm mode")
+	}
+	routed, err := b.publishLocked(exchangeName, key, msg)
+	if err != nil {
+		return err
+	}
+	if routed == 0 {
+		return pubsub.ErrUnroutable
+	}
+	return nil
+}
+
+func (ch *Channel) Consume(queueName string) (<-chan pubsub.Delivery, error) {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return nil, ErrClosed
+	}
+
+	q, ok := b.queues[queueName]
+	if !ok {
+		return nil, fmt.Errorf("no queue '%s'", queueName)
+	}
+	if q.exclusive && q.owner != ch.conn {
+		return nil, fmt.Errorf("cannot obtain exclusive access to locked queue '%s'", queueName)
+	}
+
+	c := &consumer{
+		ch:   ch,
+		q:    q,
+		out:  make(chan pubsub.Delivery),
+		done: make(chan struct{}),
+	}
+	q.consumers++
+	ch.consumers = append(ch.consumers, c)
+	go c.run()
+	return c.out, nil
+}
+
+func (ch *Channel) Close() error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return ErrClosed
+	}
+	ch.closeLocked()
+	b.notifyLocked()
+	return nil
+}
+
+func (ch *Channel) closeLocked() {
+	if ch.closed {
+		return
+	}
+	ch.closed = true
+	for _, c := range ch.consumers {
+		ch.stopConsumerLocked(c)
+	}
+	ch.consumers = nil
+	// put them back in the order they were delivered, ahead of anything
+	// still waiting
+	tags := make([]uint64, 0, len(ch.unacked))
+	for tag := range ch.unacked {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	for i := len(tags) - 1; i >= 0; i-- {
+		p := ch.unacked[tags[i]]
+		p.m.redelivered = true
+		p.q.messages = append([]*message{p.m}, p.q.messages...)
+		delete(ch.unacked, tags[i])
+	}
+	delete(ch.conn.channels, ch)
+}
+
+func (ch *Channel) stopConsumerLocked(c *consumer) {
+	close(c.done)
+	c.q.consumers--
+	b := ch.broker()
+	if c.q.autoDelete && c.q.consumers == 0 && b.queues[c.q.name] == c.q {
+		b.deleteQueueLocked(c.q.name)
+	}
+}
+
+// cancelConsumersLocked stops the channel's consumers on q, closing their
+// deliveries the way RabbitMQ cancels consumers of a deleted queue.
+func (ch *Channel) cancelConsumersLocked(q *queue) {
+	kept := ch.consumers[:0]
+	for _, c := range ch.consumers {
+		if c.q == q {
+			ch.stopConsumerLocked(c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	ch.consumers = kept
+}
+
+func (ch *Channel) settle(tag uint64, ack, requeue bool) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := ch.unacked[tag]
+	if !ok {
+		return errors.New("unknown delivery tag")
+	}
+	delete(ch.unacked, tag)
+
+	switch {
+	case ack:
+	case requeue:
+		p.m.redelivered = true
+		p.q.messages = append([]*message{p.m}, p.q.messages...)
+	default:
+		b.deadLetterLocked(p.q, p.m, "rejected")
+	}
+	b.notifyLocked()
+	return nil
+}
+
+type acknowledger struct {
+	ch  *Channel
+	tag uint64
+}
+
+func (a acknowledger) Ack() error {
+	return a.ch.settle(a.tag, true, false)
+}
+
+func (a acknowledger) Nack(requeue bool) error {
+	return a.ch.settle(a.tag, false, requeue)
+}
+
+type consumer struct {
+	ch   *Channel
+	q    *queue
+	out  chan pubsub.Delivery
+	done chan struct{}
+}
+
+func (c *consumer) run() {
+	defer close(c.out)
+	b := c.ch.broker()
+
+	for {
+		b.mu.Lock()
+		select {
+		case <-c.done:
+			b.mu.Unlock()
+			return
+		default:
+		}
+
+		full := c.ch.prefetch > 0 && len(c.ch.unacked) >= c.ch.prefetch
+		if len(c.q.messages) == 0 || full {
+			wait := b.changed
+			b.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-c.done:
+				return
+			}
+		}
+
+		m := c.q.messages[0]
+		c.q.messages = c.q.messages[1:]
+		b.nextTag++
+		tag := b.nextTag
+		c.ch.unacked[tag] = &pending{q: c.q, m: m}
+		d := pubsub.Delivery{
+			Message:      copyMessage(m.msg),
+			Exchange:     m.exchange,
+			RoutingKey:   m.key,
+			Redelivered:  m.redelivered,
+			Acknowledger: acknowledger{ch: c.ch, tag: tag},
+		}
+		b.mu.Unlock()
+
+		select {
+		case c.out <- d:
+		case <-c.done:
+			// The channel closed while this delivery was in flight; closing
+			// already requeued it along with the rest of the unacked set.
+			return
+		}
+	}
+}
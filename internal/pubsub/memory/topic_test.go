@@ -0,0 +1,41 @@
+package memory
+
+import "testing"
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"events.alice", "events.alice", true},
+		{"events.alice", "events.bob", false},
+		{"events.*", "events.alice", true},
+		{"events.*", "events", false},
+		{"events.*", "events.alice.extra", false},
+		{"*.alice", "events.alice", true},
+		{"*", "a.b", false},
+		{"events.#", "events", true},
+		{"events.#", "events.alice", true},
+		{"events.#", "events.alice.extra", true},
+		{"events.#", "intents.alice", false},
+		{"#", "", true},
+		{"#", "anything.at.all", true},
+		{"#.alice", "alice", true},
+		{"#.alice", "events.turn.alice", true},
+		{"#.alice", "events.alice.bob", false},
+		{"events.#.done", "events.done", true},
+		{"events.#.done", "events.a.b.done", true},
+		{"events.#.done", "events.a.b", false},
+		{"*.#", "events", true},
+		{"#.#", "a.b", true},
+		{"events.*.#", "events", false},
+		{"events.*.#", "events.alice", true},
+	}
+
+	for _, tc := range tests {
+		if got := topicMatch(tc.pattern, tc.key); got != tc.want {
+			t.Errorf("topicMatch(%q, %q) = %v, want %v", tc.pattern, tc.key, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"io"
+	"os"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+type subscribeOptions struct {
+	prefetch   int
+	workers    int
+	keyOrdered bool
+	retry      *RetryPolicy
+	out        io.Writer
+	names      routing.Names
+}
+
+type SubscribeOption func(*subscribeOptions)
+
+// WithPrefetch caps the number of unacknowledged messages the broker will
+// push to the subscription at once.
+func WithPrefetch(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.prefetch = n
+	}
+}
+
+// WithWorkers runs the handler on n goroutines. Without WithKeyOrdering,
+// messages are handled in no particular order.
+func WithWorkers(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.workers = n
+	}
+}
+
+// WithKeyOrdering sends every message with a given routing key to the same
+// worker, so messages sharing a key are still handled in the order they
+// were delivered.
+func WithKeyOrdering() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.keyOrdered = true
+	}
+}
+
+// WithOutput sends the subscription's trace of acks, nacks and retries to
+// w instead of stdout.
+func WithOutput(w io.Writer) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.out = w
+	}
+}
+
+// WithNames declares the subscription's queue, and any retry queues, for a
+// deployment that renames the exchanges or adds queue arguments.
+func WithNames(n routing.Names) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.names = n
+	}
+}
+
+func newSubscribeOptions(opts []SubscribeOption) subscribeOptions {
+	o := subscribeOptions{workers: 1, out: os.Stdout, names: routing.DefaultNames()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+	return o
+}
@@ -2,19 +2,16 @@ package pubsub
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"sync"
 
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 )
 
 type AckType int
 type SimpleQueueType int
 
-const (
-	ArgDeadLetterExchange = "x-dead-letter-exchange"
-)
-
 const (
 	TransientQueue SimpleQueueType = iota
 	DurableQueue
@@ -26,101 +23,289 @@ const (
 	NackRequeue
 )
 
-func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
-	valByte, err := json.Marshal(val)
+func Publish[T any](pub Publisher, codec Codec, exchange, key string, val T) error {
+	valByte, err := codec.Marshal(val)
 	if err != nil {
 		return err
 	}
 
-	return ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{
-		ContentType: "application/json",
+	return pub.Publish(context.Background(), exchange, key, Message{
+		ContentType: codec.ContentType(),
 		Body:        valByte,
 	})
 }
 
+func PublishJSON[T any](pub Publisher, exchange, key string, val T) error {
+	return Publish(pub, JSON, exchange, key, val)
+}
+
+func PublishGob[T any](pub Publisher, exchange, key string, val T) error {
+	return Publish(pub, Gob, exchange, key, val)
+}
+
 func DeclareAndBind(
-	conn *amqp.Connection,
+	broker Broker,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType SimpleQueueType,
-) (*amqp.Channel, amqp.Queue, error) {
-	chnl, err := conn.Channel()
+) (Channel, Queue, error) {
+	return declareAndBind(broker, routing.DefaultNames(), exchange, queueName, key, simpleQueueType)
+}
+
+func declareAndBind(
+	broker Broker,
+	names routing.Names,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType SimpleQueueType,
+) (Channel, Queue, error) {
+	chnl, err := broker.Channel()
 	if err != nil {
 		return wrapDeclareBindError(fmt.Errorf("couldn't create channel: %v", err))
 	}
 
-	queue, err := chnl.QueueDeclare(
-		queueName,
-		simpleQueueType == DurableQueue,
-		simpleQueueType != DurableQueue,
-		simpleQueueType != DurableQueue,
-		false,
-		amqp.Table{
-			ArgDeadLetterExchange: "peril_dlx",
-		},
-	)
-	if err != nil {
-		return wrapDeclareBindError(fmt.Errorf("couldn't declare queue: %v", err))
+	queue := routing.TransientQueue(names, queueName)
+	if simpleQueueType == DurableQueue {
+		queue = routing.DurableQueue(names, queueName)
 	}
 
-	if err = chnl.QueueBind(queueName, key, exchange, false, nil); err != nil {
-		return wrapDeclareBindError(fmt.Errorf("couldn't bind queue: %v", err))
+	if err = ApplyTopology(chnl, routing.PerilTopology(names).Merge(routing.Topology{
+		Queues:   []routing.Queue{queue},
+		Bindings: []routing.Binding{{Queue: queueName, Exchange: exchange, Key: key}},
+	})); err != nil {
+		chnl.Close()
+		return wrapDeclareBindError(err)
 	}
 
-	return chnl, queue, nil
+	return chnl, Queue{Name: queueName}, nil
 }
 
-func SubscribeJSON[T any](
-	conn *amqp.Connection,
+// Subscription is a running consumer started by Subscribe.
+type Subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Close stops consuming and waits for the in-flight handler, if any, to
+// finish and settle its message. Messages already prefetched but not yet
+// handled are requeued when the subscription's channel closes.
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Wait blocks until the subscription stops, either because its context was
+// cancelled or because the broker closed the deliveries.
+func (s *Subscription) Wait() {
+	<-s.done
+}
+
+// Subscribe consumes from queueName until ctx is cancelled, decoding each
+// message with the codec named by its ContentType so producers using
+// different codecs can share a queue. Messages without a ContentType are
+// decoded with fallback.
+func Subscribe[T any](
+	ctx context.Context,
+	broker Broker,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType SimpleQueueType,
+	fallback Codec,
 	handler func(T) AckType,
-) error {
-	chnl, que, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	return SubscribeKeyed(ctx, broker, exchange, queueName, key, simpleQueueType, fallback, func(_ string, val T) AckType {
+		return handler(val)
+	}, opts...)
+}
+
+// SubscribeKeyed is Subscribe for handlers that need to know the routing
+// key each message was published with, e.g. to check who sent it.
+func SubscribeKeyed[T any](
+	ctx context.Context,
+	broker Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType SimpleQueueType,
+	fallback Codec,
+	handler func(key string, val T) AckType,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	o := newSubscribeOptions(opts)
+
+	chnl, que, err := declareAndBind(broker, o.names, exchange, queueName, key, simpleQueueType)
 	if err != nil {
-		return fmt.Errorf("could not declare and bind queue: %v", err)
+		return nil, fmt.Errorf("could not declare and bind queue: %v", err)
 	}
 
-	msgs, err := chnl.Consume(que.Name, "", false, false, false, false, nil)
+	if o.prefetch > 0 {
+		if err := chnl.Qos(o.prefetch); err != nil {
+			chnl.Close()
+			return nil, fmt.Errorf("could not set prefetch: %v", err)
+		}
+	}
+
+	var retries *retrier
+	if o.retry != nil {
+		if retries, err = newRetrier(chnl, o.names, que.Name, simpleQueueType, *o.retry); err != nil {
+			chnl.Close()
+			return nil, err
+		}
+	}
+
+	msgs, err := chnl.Consume(que.Name)
 	if err != nil {
-		return fmt.Errorf("could not consume messages: %v", err)
+		chnl.Close()
+		return nil, fmt.Errorf("could not consume messages: %v", err)
 	}
 
-	unmarshaller := func(data []byte) (T, error) {
+	unmarshaller := func(msg Delivery) (T, error) {
 		var target T
-		err := json.Unmarshal(data, &target)
+		codec := fallback
+		if msg.ContentType != "" {
+			c, err := CodecFor(msg.ContentType)
+			if err != nil {
+				return target, err
+			}
+			codec = c
+		}
+		err := codec.Unmarshal(msg.Body, &target)
 		return target, err
 	}
 
+	handle := func(msg Delivery) {
+		target, err := unmarshaller(msg)
+		if err != nil {
+			fmt.Fprintf(o.out, "could not unmarshal message: %v\n", err)
+			msg.Nack(false)
+			return
+		}
+
+		switch handler(msg.RoutingKey, target) {
+		case Ack:
+			msg.Ack()
+			fmt.Fprintln(o.out, "Ack")
+		case NackDiscard:
+			msg.Nack(false)
+			fmt.Fprintln(o.out, "NackDiscard")
+		case NackRequeue:
+			fmt.Fprintln(o.out, "NackRequeue")
+			if retries == nil {
+				msg.Nack(true)
+			} else if err := retries.retry(msg, o.out); err != nil {
+				fmt.Fprintf(o.out, "could not retry message: %v\n", err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &Subscription{cancel: cancel, done: make(chan struct{})}
+
+	queues := make([]chan Delivery, o.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan Delivery)
+		wg.Add(1)
+		go func(work <-chan Delivery) {
+			defer wg.Done()
+			for msg := range work {
+				handle(msg)
+			}
+		}(queues[i])
+	}
+
 	go func() {
+		defer close(sub.done)
 		defer chnl.Close()
-		for msg := range msgs {
-			target, err := unmarshaller(msg.Body)
-			if err != nil {
-				fmt.Printf("could not unmarshal message: %v\n", err)
-				continue
+		defer wg.Wait()
+		defer func() {
+			for _, q := range queues {
+				close(q)
 			}
+		}()
 
-			switch handler(target) {
-			case Ack:
-				msg.Ack(false)
-				fmt.Println("Ack")
-			case NackDiscard:
-				msg.Nack(false, false)
-				fmt.Println("NackDiscard")
-			case NackRequeue:
-				msg.Nack(false, true)
-				fmt.Println("NackRequeue")
+		next := 0
+		for {
+			var msg Delivery
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok = <-msgs:
+				if !ok {
+					return
+				}
+			}
+			msg = restoreRoute(msg, que.Name)
+
+			worker := next
+			if o.keyOrdered {
+				worker = int(keyHash(msg.RoutingKey) % uint32(len(queues)))
+			} else {
+				next = (next + 1) % len(queues)
+			}
+
+			select {
+			case queues[worker] <- msg:
+			case <-ctx.Done():
+				// not handled; it is requeued when the channel closes
+				return
 			}
 		}
 	}()
 
-	return nil
+	return sub, nil
+}
+
+func SubscribeJSON[T any](
+	ctx context.Context,
+	broker Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType SimpleQueueType,
+	handler func(T) AckType,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	return Subscribe(ctx, broker, exchange, queueName, key, simpleQueueType, JSON, handler, opts...)
+}
+
+func SubscribeJSONKeyed[T any](
+	ctx context.Context,
+	broker Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType SimpleQueueType,
+	handler func(key string, val T) AckType,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	return SubscribeKeyed(ctx, broker, exchange, queueName, key, simpleQueueType, JSON, handler, opts...)
+}
+
+func SubscribeGob[T any](
+	ctx context.Context,
+	broker Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType SimpleQueueType,
+	handler func(T) AckType,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	return Subscribe(ctx, broker, exchange, queueName, key, simpleQueueType, Gob, handler, opts...)
+}
+
+func keyHash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
 }
 
-func wrapDeclareBindError(err error) (*amqp.Channel, amqp.Queue, error) {
-	return nil, amqp.Queue{}, err
+func wrapDeclareBindError(err error) (Channel, Queue, error) {
+	return nil, Queue{}, err
 }
@@ -0,0 +1,367 @@
+package pubsub_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub/memory"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const testTimeout = 2 * time.Second
+
+func TestSubscribeAckTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		acks      []pubsub.AckType
+		wantCalls int
+		wantDead  int
+	}{
+		{"ack", []pubsub.AckType{pubsub.Ack}, 1, 0},
+		{"nack discard", []pubsub.AckType{pubsub.NackDiscard}, 1, 1},
+		{"nack requeue", []pubsub.AckType{pubsub.NackRequeue, pubsub.Ack}, 2, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, broker := newBroker(t)
+			calls := make(chan string, len(tc.acks))
+			var mu sync.Mutex
+			n := 0
+			subscribe(t, broker, func(msg string) pubsub.AckType {
+				mu.Lock()
+				defer mu.Unlock()
+				ack := tc.acks[min(n, len(tc.acks)-1)]
+				n++
+				calls <- msg
+				return ack
+			})
+
+			publishJSON(t, broker, routing.GameLogKey("alice"), "hello")
+			for range tc.wantCalls {
+				if got := wait(t, calls); got != "hello" {
+					t.Errorf("handler got %q, want hello", got)
+				}
+			}
+			eventually(t, "the message to settle", func() bool {
+				return b.QueueLength("logs") == 0 && b.QueueLength(routing.DeadLetterQueue) == tc.wantDead
+			})
+		})
+	}
+}
+
+func TestSubscribeClose(t *testing.T) {
+	b, broker := newBroker(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	sub, err := pubsub.SubscribeJSON(context.Background(), broker, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), pubsub.DurableQueue,
+		func(string) pubsub.AckType {
+			close(started)
+			<-release
+			return pubsub.Ack
+		}, pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishJSON(t, broker, routing.GameLogKey("alice"), "slow")
+	wait(t, started)
+
+	closed := make(chan struct{})
+	go func() {
+		sub.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the in-flight handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	wait(t, closed)
+	if got := b.QueueLength("logs"); got != 0 {
+		t.Errorf("queue has %d messages, want the in-flight one acked", got)
+	}
+}
+
+func TestSubscribeContextCancel(t *testing.T) {
+	b, broker := newBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := pubsub.SubscribeJSON(ctx, broker, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), pubsub.DurableQueue,
+		func(string) pubsub.AckType {
+			t.Error("handler called after the context was cancelled")
+			return pubsub.Ack
+		}, pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		sub.Wait()
+		close(stopped)
+	}()
+	wait(t, stopped)
+
+	publishJSON(t, broker, routing.GameLogKey("alice"), "later")
+	if got := b.QueueLength("logs"); got != 1 {
+		t.Errorf("queue has %d messages, want 1 waiting for the next consumer", got)
+	}
+}
+
+func TestSubscribeCodecs(t *testing.T) {
+	_, broker := newBroker(t)
+	got := make(chan string, 3)
+	subscribe(t, broker, func(msg string) pubsub.AckType {
+		got <- msg
+		return pubsub.Ack
+	})
+
+	ch := channel(t, broker)
+	if err := pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.GameLogKey("a"), "json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.PublishGob(ch, routing.ExchangePerilTopic, routing.GameLogKey("a"), "gob"); err != nil {
+		t.Fatal(err)
+	}
+	// no content type: decoded with the subscription's fallback codec
+	if err := ch.Publish(context.Background(), routing.ExchangePerilTopic, routing.GameLogKey("a"), pubsub.Message{Body: []byte(`"bare"`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"json", "gob", "bare"} {
+		if msg := wait(t, got); msg != want {
+			t.Errorf("got %q, want %q", msg, want)
+		}
+	}
+}
+
+func TestSubscribeUndecodable(t *testing.T) {
+	b, broker := newBroker(t)
+	subscribe(t, broker, func(string) pubsub.AckType {
+		t.Error("handler called for a message that can't be decoded")
+		return pubsub.Ack
+	})
+
+	ch := channel(t, broker)
+	for _, msg := range []pubsub.Message{
+		{ContentType: pubsub.ContentTypeJSON, Body: []byte("{not json")},
+		{ContentType: "text/unknown", Body: []byte("hi")},
+	} {
+		if err := ch.Publish(context.Background(), routing.ExchangePerilTopic, routing.GameLogKey("a"), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "both messages to be dead-lettered", func() bool {
+		return b.QueueLength(routing.DeadLetterQueue) == 2
+	})
+}
+
+func TestSubscribeKeyed(t *testing.T) {
+	_, broker := newBroker(t)
+	keys := make(chan string, 1)
+	sub, err := pubsub.SubscribeJSONKeyed(context.Background(), broker, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), pubsub.DurableQueue,
+		func(key string, _ string) pubsub.AckType {
+			keys <- key
+			return pubsub.Ack
+		}, pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	publishJSON(t, broker, routing.GameLogKey("alice"), "hello")
+	if got := wait(t, keys); got != routing.GameLogKey("alice") {
+		t.Errorf("got key %q, want %q", got, routing.GameLogKey("alice"))
+	}
+}
+
+func TestSubscribeWorkers(t *testing.T) {
+	_, broker := newBroker(t)
+	const workers = 3
+	entered := make(chan struct{}, workers)
+	release := make(chan struct{})
+	subscribe(t, broker, func(string) pubsub.AckType {
+		entered <- struct{}{}
+		<-release
+		return pubsub.Ack
+	}, pubsub.WithWorkers(workers), pubsub.WithPrefetch(workers))
+
+	for range workers {
+		publishJSON(t, broker, routing.GameLogKey("alice"), "job")
+	}
+	// every handler blocks until all of them are running at once
+	for range workers {
+		wait(t, entered)
+	}
+	close(release)
+}
+
+func TestSubscribeKeyOrdering(t *testing.T) {
+	_, broker := newBroker(t)
+	const perKey = 30
+	players := []string{"alice", "bob", "carol"}
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	done := make(chan struct{})
+	sub, err := pubsub.SubscribeJSONKeyed(context.Background(), broker, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), pubsub.DurableQueue,
+		func(key string, n int) pubsub.AckType {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[key] = append(seen[key], n)
+			total := 0
+			for _, ns := range seen {
+				total += len(ns)
+			}
+			if total == perKey*len(players) {
+				close(done)
+			}
+			return pubsub.Ack
+		}, pubsub.WithWorkers(4), pubsub.WithKeyOrdering(), pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	ch := channel(t, broker)
+	for i := range perKey {
+		for _, p := range players {
+			if err := pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.GameLogKey(p), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("not every message was handled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for key, ns := range seen {
+		for i, n := range ns {
+			if n != i {
+				t.Fatalf("%s handled out of order: %v", key, ns)
+			}
+		}
+	}
+}
+
+func TestSubscribeRetry(t *testing.T) {
+	b, broker := newBroker(t)
+	calls := make(chan string, 10)
+	subscribe(t, broker, func(msg string) pubsub.AckType {
+		calls <- msg
+		return pubsub.NackRequeue
+	}, pubsub.WithRetry(pubsub.RetryPolicy{Delays: []time.Duration{10 * time.Millisecond}, MaxAttempts: 3}))
+
+	publishJSON(t, broker, routing.GameLogKey("alice"), "flaky")
+	for range 3 {
+		wait(t, calls)
+	}
+	eventually(t, "the message to be given up on", func() bool {
+		return b.QueueLength(routing.DeadLetterQueue) == 1
+	})
+	select {
+	case <-calls:
+		t.Error("message was retried past MaxAttempts")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscribeRetryKeepsKey(t *testing.T) {
+	_, broker := newBroker(t)
+	keys := make(chan string, 2)
+	var mu sync.Mutex
+	calls := 0
+	sub, err := pubsub.SubscribeJSONKeyed(context.Background(), broker, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), pubsub.DurableQueue,
+		func(key string, _ string) pubsub.AckType {
+			mu.Lock()
+			defer mu.Unlock()
+			keys <- key
+			calls++
+			if calls == 1 {
+				return pubsub.NackRequeue
+			}
+			return pubsub.Ack
+		},
+		pubsub.WithRetry(pubsub.RetryPolicy{Delays: []time.Duration{10 * time.Millisecond}, MaxAttempts: 3}),
+		pubsub.WithWorkers(2), pubsub.WithKeyOrdering(), pubsub.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	publishJSON(t, broker, routing.GameLogKey("alice"), "flaky")
+	for _, attempt := range []string{"first", "retried"} {
+		if got := wait(t, keys); got != routing.GameLogKey("alice") {
+			t.Errorf("%s delivery has key %q, want %q", attempt, got, routing.GameLogKey("alice"))
+		}
+	}
+}
+
+func newBroker(t *testing.T) (*memory.Broker, pubsub.Broker) {
+	t.Helper()
+	b := memory.NewBroker()
+	broker, err := b.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	return b, broker
+}
+
+func channel(t *testing.T, broker pubsub.Broker) pubsub.Channel {
+	t.Helper()
+	ch, err := broker.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+// subscribe consumes game logs from the durable "logs" queue.
+func subscribe(t *testing.T, broker pubsub.Broker, handler func(string) pubsub.AckType, opts ...pubsub.SubscribeOption) {
+	t.Helper()
+	opts = append(opts, pubsub.WithOutput(io.Discard))
+	sub, err := pubsub.SubscribeJSON(context.Background(), broker, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), pubsub.DurableQueue, handler, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sub.Close)
+}
+
+func publishJSON(t *testing.T, broker pubsub.Broker, key, msg string) {
+	t.Helper()
+	if err := pubsub.PublishJSON(channel(t, broker), routing.ExchangePerilTopic, key, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wait[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(testTimeout):
+		t.Fatal("timed out")
+	}
+	var zero T
+	return zero
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,599 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotConnected = errors.New("broker connection is down")
+
+	errChannelReplaced = errors.New("channel was already replaced")
+)
+
+// Dialer opens a fresh connection to the broker.
+type Dialer func() (Broker, error)
+
+// CloseNotifier is implemented by brokers that can report a lost connection,
+// and by channels that can report being closed by the broker. The returned
+// channel receives the cause (if any) and is then closed.
+type CloseNotifier interface {
+	NotifyClose() <-chan error
+}
+
+// ReconnectOptions bound the wait between reconnect attempts. Zero or
+// negative values fall back to the defaults.
+type ReconnectOptions struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func (o ReconnectOptions) withDefaults() ReconnectOptions {
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = defaultReconnectOptions.MinBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultReconnectOptions.MaxBackoff
+	}
+	o.MaxBackoff = max(o.MaxBackoff, o.MinBackoff)
+	return o
+}
+
+var defaultReconnectOptions = ReconnectOptions{
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// ManagedBroker is a Broker that survives connection loss. Every channel it
+// hands out remembers the topology declared through it and the consumers
+// started on it; after a reconnect the topology is declared again and the
+// consumers resume delivering on the same Go channels they returned. A
+// channel the broker closes on its own, or one of whose consumers it
+// cancels (e.g. because the queue was deleted), is replaced the same way
+// without waiting for the connection to drop.
+type ManagedBroker struct {
+	dial Dialer
+	opts ReconnectOptions
+
+	mu       sync.Mutex
+	conn     Broker
+	channels map[*managedChannel]struct{}
+	closed   bool
+}
+
+// NewManagedBroker dials once and then keeps the connection alive in the
+// background. The dial must implement CloseNotifier for drops to be noticed.
+func NewManagedBroker(dial Dialer, opts ...ReconnectOptions) (*ManagedBroker, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	mb := &ManagedBroker{
+		dial:     dial,
+		opts:     defaultReconnectOptions,
+		conn:     conn,
+		channels: map[*managedChannel]struct{}{},
+	}
+	if len(opts) > 0 {
+		mb.opts = opts[0].withDefaults()
+	}
+	go mb.watch(conn)
+	return mb, nil
+}
+
+// AMQPDialer returns a Dialer for the RabbitMQ server at url.
+func AMQPDialer(url string) Dialer {
+	return AMQPDialerWith(url, AMQPOptions{})
+}
+
+// AMQPDialerWith returns a Dialer for the RabbitMQ server at url using opts.
+func AMQPDialerWith(url string, opts AMQPOptions) Dialer {
+	return func() (Broker, error) {
+		return DialAMQPWith(url, opts)
+	}
+}
+
+func (mb *ManagedBroker) Channel() (Channel, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.closed {
+		return nil, ErrNotConnected
+	}
+	if mb.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	inner, err := mb.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	mc := &managedChannel{mb: mb, ch: inner}
+	mb.channels[mc] = struct{}{}
+	go mc.watch(inner)
+	return mc, nil
+}
+
+func (mb *ManagedBroker) Close() error {
+	mb.mu.Lock()
+	if mb.closed {
+		mb.mu.Unlock()
+		return nil
+	}
+	mb.closed = true
+	conn := mb.conn
+	channels := mb.channels
+	mb.channels = nil
+	mb.mu.Unlock()
+
+	for mc := range channels {
+		mc.Close()
+	}
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+func (mb *ManagedBroker) isClosed() bool {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return mb.closed
+}
+
+func (mb *ManagedBroker) watch(conn Broker) {
+	for {
+		notifier, ok := conn.(CloseNotifier)
+		if !ok {
+			return
+		}
+		err := <-notifier.NotifyClose()
+		if mb.isClosed() {
+			return
+		}
+		log.Printf("broker connection lost: %v", err)
+
+		mb.mu.Lock()
+		mb.conn = nil
+		for mc := range mb.channels {
+			mc.detach()
+		}
+		mb.mu.Unlock()
+
+		conn = mb.reconnect()
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect dials with exponential backoff until it succeeds and every
+// channel has been restored, or the broker is closed.
+func (mb *ManagedBroker) reconnect() Broker {
+	backoff := mb.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		if mb.isClosed() {
+			return nil
+		}
+
+		conn, err := mb.dial()
+		if err == nil {
+			err = mb.restore(conn)
+			if err == nil {
+				log.Printf("broker connection restored after %d attempt(s)", attempt)
+				return conn
+			}
+			conn.Close()
+		}
+
+		wait := jitter(backoff)
+		log.Printf("reconnect attempt %d failed: %v (retrying in %v)", attempt, err, wait)
+		time.Sleep(wait)
+		backoff = min(backoff*2, mb.opts.MaxBackoff)
+	}
+}
+
+func (mb *ManagedBroker) current() Broker {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return mb.conn
+}
+
+// jitter spreads retries out so that clients dropped together don't all
+// come back at the same moment.
+func jitter(backoff time.Duration) time.Duration {
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func (mb *ManagedBroker) restore(conn Broker) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.closed {
+		return ErrNotConnected
+	}
+
+	for mc := range mb.channels {
+		inner, err := conn.Channel()
+		if err == nil {
+			err = mc.attach(inner)
+		}
+		if err != nil {
+			for mc := range mb.channels {
+				mc.detach()
+			}
+			return err
+		}
+	}
+	mb.conn = conn
+	return nil
+}
+
+type exchangeDecl struct {
+	name    string
+	kind    string
+	durable bool
+}
+
+type queueDecl struct {
+	name       string
+	durable    bool
+	autoDelete bool
+	exclusive  bool
+	args       map[string]any
+}
+
+type bindingDecl struct {
+	queue    string
+	key      string
+	exchange string
+}
+
+type managedChannel struct {
+	mb *ManagedBroker
+
+	mu        sync.Mutex
+	ch        Channel
+	exchanges []exchangeDecl
+	queues    []queueDecl
+	bindings  []bindingDecl
+	consumers []*managedConsumer
+	prefetch  int
+	confirm   bool
+	closed    bool
+}
+
+func (mc *managedChannel) current() (Channel, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch == nil {
+		return nil, ErrNotConnected
+	}
+	return mc.ch, nil
+}
+
+func (mc *managedChannel) ExchangeDeclare(name, kind string, durable bool) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch == nil {
+		return ErrNotConnected
+	}
+	if err := mc.ch.ExchangeDeclare(name, kind, durable); err != nil {
+		return err
+	}
+	mc.exchanges = append(mc.exchanges, exchangeDecl{name: name, kind: kind, durable: durable})
+	return nil
+}
+
+func (mc *managedChannel) QueueDeclare(name string, durable, autoDelete, exclusive bool, args map[string]any) (Queue, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch == nil {
+		return Queue{}, ErrNotConnected
+	}
+	q, err := mc.ch.QueueDeclare(name, durable, autoDelete, exclusive, args)
+	if err != nil {
+		return Queue{}, err
+	}
+	mc.queues = append(mc.queues, queueDecl{
+		name:       q.Name,
+		durable:    durable,
+		autoDelete: autoDelete,
+		exclusive:  exclusive,
+		args:       args,
+	})
+	return q, nil
+}
+
+func (mc *managedChannel) QueueBind(queue, key, exchange string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch == nil {
+		return ErrNotConnected
+	}
+	if err := mc.ch.QueueBind(queue, key, exchange); err != nil {
+		return err
+	}
+	mc.bindings = append(mc.bindings, bindingDecl{queue: queue, key: key, exchange: exchange})
+	return nil
+}
+
+func (mc *managedChannel) Qos(prefetch int) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch == nil {
+		return ErrNotConnected
+	}
+	if err := mc.ch.Qos(prefetch); err != nil {
+		return err
+	}
+	mc.prefetch = prefetch
+	return nil
+}
+
+func (mc *managedChannel) Publish(ctx context.Context, exchange, key string, msg Message) error {
+	ch, err := mc.current()
+	if err != nil {
+		return err
+	}
+	return ch.Publish(ctx, exchange, key, msg)
+}
+
+func (mc *managedChannel) Confirm() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch == nil {
+		return ErrNotConnected
+	}
+	cc, ok := mc.ch.(ConfirmChannel)
+	if !ok {
+		return errors.New("channel does not support publisher confirms")
+	}
+	if err := cc.Confirm(); err != nil {
+		return err
+	}
+	mc.confirm = true
+	return nil
+}
+
+func (mc *managedChannel) PublishConfirmed(ctx context.Context, exchange, key string, msg Message) error {
+	ch, err := mc.current()
+	if err != nil {
+		return err
+	}
+	cc, ok := ch.(ConfirmChannel)
+	if !ok {
+		return errors.New("channel does not support publisher confirms")
+	}
+	return cc.PublishConfirmed(ctx, exchange, key, msg)
+}
+
+func (mc *managedChannel) Consume(queue string) (<-chan Delivery, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch == nil {
+		return nil, ErrNotConnected
+	}
+	src, err := mc.ch.Consume(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &managedConsumer{
+		mc:      mc,
+		queue:   queue,
+		out:     make(chan Delivery),
+		sources: make(chan source, 1),
+		done:    make(chan struct{}),
+	}
+	c.sources <- source{ch: mc.ch, deliveries: src}
+	mc.consumers = append(mc.consumers, c)
+	go c.run()
+	return c.out, nil
+}
+
+func (mc *managedChannel) Close() error {
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		return nil
+	}
+	mc.closed = true
+	ch := mc.ch
+	mc.ch = nil
+	for _, c := range mc.consumers {
+		close(c.done)
+	}
+	mc.mu.Unlock()
+
+	mc.mb.mu.Lock()
+	delete(mc.mb.channels, mc)
+	mc.mb.mu.Unlock()
+
+	if ch != nil {
+		return ch.Close()
+	}
+	return nil
+}
+
+func (mc *managedChannel) detach() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.ch = nil
+}
+
+// attach replays the recorded topology and consumers onto a channel from a
+// new connection.
+func (mc *managedChannel) attach(ch Channel) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		return nil
+	}
+	return mc.attachLocked(ch)
+}
+
+// watch recovers the channel if the broker closes it, e.g. after a channel
+// exception, while the connection stays up.
+func (mc *managedChannel) watch(ch Channel) {
+	notifier, ok := ch.(CloseNotifier)
+	if !ok {
+		return
+	}
+	if err := <-notifier.NotifyClose(); err != nil {
+		mc.recover(ch, err)
+	}
+}
+
+// recover replaces failed, a channel the broker closed or cancelled a
+// consumer on, with a fresh one from the current connection. It gives up
+// once failed is no longer the current channel: a lost connection is
+// ManagedBroker.watch's to restore.
+func (mc *managedChannel) recover(failed Channel, cause error) {
+	backoff := mc.mb.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		mc.mu.Lock()
+		stale := mc.closed || mc.ch != failed
+		mc.mu.Unlock()
+		conn := mc.mb.current()
+		if stale || conn == nil {
+			return
+		}
+
+		inner, err := conn.Channel()
+		if err == nil {
+			err = mc.replace(failed, inner)
+			if errors.Is(err, errChannelReplaced) {
+				inner.Close()
+				return
+			}
+			if err == nil {
+				log.Printf("broker channel restored after %d attempt(s): %v", attempt, cause)
+				return
+			}
+			inner.Close()
+		}
+
+		wait := jitter(backoff)
+		log.Printf("channel recovery attempt %d failed: %v (retrying in %v)", attempt, err, wait)
+		time.Sleep(wait)
+		backoff = min(backoff*2, mc.mb.opts.MaxBackoff)
+	}
+}
+
+// replace swaps failed for ch and replays everything onto it, unless failed
+// has been replaced already.
+func (mc *managedChannel) replace(failed, ch Channel) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || mc.ch != failed {
+		return errChannelReplaced
+	}
+	// whatever still works on it goes too; it's all replayed below
+	failed.Close()
+	mc.ch = nil
+	return mc.attachLocked(ch)
+}
+
+func (mc *managedChannel) attachLocked(ch Channel) error {
+	if mc.confirm {
+		cc, ok := ch.(ConfirmChannel)
+		if !ok {
+			return errors.New("channel does not support publisher confirms")
+		}
+		if err := cc.Confirm(); err != nil {
+			return err
+		}
+	}
+	if mc.prefetch > 0 {
+		if err := ch.Qos(mc.prefetch); err != nil {
+			return err
+		}
+	}
+	for _, ex := range mc.exchanges {
+		if err := ch.ExchangeDeclare(ex.name, ex.kind, ex.durable); err != nil {
+			return err
+		}
+	}
+	for _, q := range mc.queues {
+		if _, err := ch.QueueDeclare(q.name, q.durable, q.autoDelete, q.exclusive, q.args); err != nil {
+			return err
+		}
+	}
+	for _, b := range mc.bindings {
+		if err := ch.QueueBind(b.queue, b.key, b.exchange); err != nil {
+			return err
+		}
+	}
+
+	sources := make([]<-chan Delivery, len(mc.consumers))
+	for i, c := range mc.consumers {
+		src, err := ch.Consume(c.queue)
+		if err != nil {
+			return err
+		}
+		sources[i] = src
+	}
+	for i, c := range mc.consumers {
+		c.setSource(source{ch: ch, deliveries: sources[i]})
+	}
+	mc.ch = ch
+	go mc.watch(ch)
+	return nil
+}
+
+// managedConsumer forwards deliveries from whichever underlying consumer is
+// current onto a single output channel that outlives reconnects.
+type managedConsumer struct {
+	mc      *managedChannel
+	queue   string
+	out     chan Delivery
+	sources chan source
+	done    chan struct{}
+}
+
+// source is an underlying consumer and the channel it was started on.
+type source struct {
+	ch         Channel
+	deliveries <-chan Delivery
+}
+
+func (c *managedConsumer) setSource(src source) {
+	select {
+	case <-c.sources:
+	default:
+	}
+	c.sources <- src
+}
+
+func (c *managedConsumer) run() {
+	defer close(c.out)
+	for {
+		var src source
+		select {
+		case src = <-c.sources:
+		case <-c.done:
+			return
+		}
+
+		for d := range src.deliveries {
+			select {
+			case c.out <- d:
+			case <-c.done:
+				return
+			}
+		}
+
+		// the broker cancelled the consumer or closed its channel
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		go c.mc.recover(src.ch, fmt.Errorf("consumer on %s was cancelled", c.queue))
+	}
+}
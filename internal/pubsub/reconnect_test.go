@@ -0,0 +1,70 @@
+package pubsub_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub/memory"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var testReconnect = pubsub.ReconnectOptions{MinBackoff: 5 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+func TestManagedBrokerRecovers(t *testing.T) {
+	tests := []struct {
+		name  string
+		queue pubsub.SimpleQueueType
+		fail  func(b *memory.Broker)
+	}{
+		{"durable queue across a restart", pubsub.DurableQueue, (*memory.Broker).Restart},
+		{"transient queue across a restart", pubsub.TransientQueue, (*memory.Broker).Restart},
+		{"deleted queue", pubsub.DurableQueue, func(b *memory.Broker) { b.DeleteQueue("logs") }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := memory.NewBroker()
+			mb, err := pubsub.NewManagedBroker(b.Dial, testReconnect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mb.Close()
+
+			got := make(chan string, 10)
+			sub, err := pubsub.SubscribeJSON(context.Background(), mb, routing.ExchangePerilTopic, "logs", routing.AllKeys(routing.GameLogSlug), tc.queue,
+				func(msg string) pubsub.AckType {
+					got <- msg
+					return pubsub.Ack
+				}, pubsub.WithOutput(io.Discard))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Close()
+			// opened before the failure, so it has to be restored too
+			publisher := channel(t, mb)
+
+			if err := pubsub.PublishJSON(publisher, routing.ExchangePerilTopic, routing.GameLogKey("alice"), "before"); err != nil {
+				t.Fatal(err)
+			}
+			if msg := wait(t, got); msg != "before" {
+				t.Fatalf("got %q, want before", msg)
+			}
+
+			tc.fail(b)
+			// anything published before the queue is back is lost, so keep
+			// trying until a message makes it through
+			eventually(t, "the subscription to be restored", func() bool {
+				pubsub.PublishJSON(publisher, routing.ExchangePerilTopic, routing.GameLogKey("alice"), "after")
+				select {
+				case msg := <-got:
+					return msg == "after"
+				case <-time.After(10 * time.Millisecond):
+					return false
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const (
+	HeaderRetryCount       = "x-retry-count"
+	HeaderOriginalExchange = "x-original-exchange"
+	HeaderOriginalKey      = "x-original-routing-key"
+)
+
+// RetryPolicy turns NackRequeue into a delayed retry. The message is parked
+// in a retry queue for Delays[n] (the last delay is reused once the list runs
+// out), after which the broker dead-letters it back to the source queue.
+// Once a message has been attempted MaxAttempts times it is discarded to
+// the dead letter queue instead.
+type RetryPolicy struct {
+	Delays      []time.Duration
+	MaxAttempts int
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Delays:      []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+	MaxAttempts: 10,
+}
+
+// WithRetry enables delayed retries for messages the handler NackRequeues.
+func WithRetry(policy RetryPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.retry = &policy
+	}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	if attempt >= len(p.Delays) {
+		return p.Delays[len(p.Delays)-1]
+	}
+	return p.Delays[attempt]
+}
+
+type retrier struct {
+	ch     Channel
+	policy RetryPolicy
+	queues map[time.Duration]string
+}
+
+// newRetrier declares one retry queue per distinct delay, with names'
+// QueueArgs. Messages that expire in a retry queue go through the default
+// exchange straight back to queueName.
+func newRetrier(ch Channel, names routing.Names, queueName string, simpleQueueType SimpleQueueType, policy RetryPolicy) (*retrier, error) {
+	if len(policy.Delays) == 0 || policy.MaxAttempts < 1 {
+		return nil, fmt.Errorf("retry policy needs at least one delay and one attempt")
+	}
+
+	r := &retrier{ch: ch, policy: policy, queues: map[time.Duration]string{}}
+	for _, delay := range policy.Delays {
+		if _, ok := r.queues[delay]; ok {
+			continue
+		}
+		name := fmt.Sprintf("%s.retry.%s", queueName, delay)
+		args := names.QueueArgsWith(map[string]any{
+			"x-message-ttl":               delay.Milliseconds(),
+			routing.ArgDeadLetterExchange: "",
+			"x-dead-letter-routing-key":   queueName,
+		})
+		if simpleQueueType != DurableQueue {
+			// transient queues come and go with their client; let the
+			// broker remove an abandoned retry queue as well
+			args["x-expires"] = (delay + time.Minute).Milliseconds()
+		}
+		if _, err := ch.QueueDeclare(name, simpleQueueType == DurableQueue, false, false, args); err != nil {
+			return nil, fmt.Errorf("couldn't declare retry queue %s: %v", name, err)
+		}
+		r.queues[delay] = name
+	}
+	return r, nil
+}
+
+// retry settles msg for a NackRequeue: it is parked in the retry queue for
+// its attempt, or dead-lettered once the policy is exhausted.
+func (r *retrier) retry(msg Delivery, trace io.Writer) error {
+	attempt := retryCount(msg.Headers)
+	if attempt+1 >= r.policy.MaxAttempts {
+		fmt.Fprintf(trace, "giving up after %d attempts\n", attempt+1)
+		return msg.Nack(false)
+	}
+
+	out := msg.Message
+	out.Headers = make(map[string]any, len(msg.Headers)+3)
+	for k, v := range msg.Headers {
+		out.Headers[k] = v
+	}
+	out.Headers[HeaderRetryCount] = int64(attempt + 1)
+	// msg's route has already been restored if this isn't its first retry
+	out.Headers[HeaderOriginalExchange] = msg.Exchange
+	out.Headers[HeaderOriginalKey] = msg.RoutingKey
+
+	delay := r.policy.delay(attempt)
+	if err := r.ch.Publish(context.Background(), "", r.queues[delay], out); err != nil {
+		// fall back to an immediate requeue rather than losing the message
+		msg.Nack(true)
+		return fmt.Errorf("couldn't schedule retry: %v", err)
+	}
+	fmt.Fprintf(trace, "retrying in %v (attempt %d)\n", delay, attempt+2)
+	return msg.Ack()
+}
+
+func retryCount(headers map[string]any) int {
+	switch n := headers[HeaderRetryCount].(type) {
+	case int:
+		return n
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	}
+	return 0
+}
+
+// restoreRoute undoes a message's detour through a retry queue: it comes
+// back through the default exchange keyed by the queue's name, so the
+// exchange and key it was first published with are put back from the
+// headers the retrier set. Anyone who may publish to the queue directly can
+// set those headers too, just as they could pick any routing key.
+func restoreRoute(msg Delivery, queueName string) Delivery {
+	if msg.Exchange != "" || msg.RoutingKey != queueName {
+		return msg
+	}
+	if exchange, ok := msg.Headers[HeaderOriginalExchange].(string); ok {
+		msg.Exchange = exchange
+		msg.RoutingKey, _ = msg.Headers[HeaderOriginalKey].(string)
+	}
+	return msg
+}
@@ -0,0 +1,30 @@
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// ApplyTopology declares every exchange and queue in t and then binds them.
+// Declarations are idempotent, so it is safe to call on every startup.
+func ApplyTopology(ch Channel, t routing.Topology) error {
+	for _, ex := range t.Exchanges {
+		if err := ch.ExchangeDeclare(ex.Name, ex.Kind, ex.Durable); err != nil {
+			return fmt.Errorf("couldn't declare exchange %s: %v", ex.Name, err)
+		}
+	}
+
+	for _, q := range t.Queues {
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, q.Args); err != nil {
+			return fmt.Errorf("couldn't declare queue %s: %v", q.Name, err)
+		}
+	}
+
+	for _, b := range t.Bindings {
+		if err := ch.QueueBind(b.Queue, b.Key, b.Exchange); err != nil {
+			return fmt.Errorf("couldn't bind queue %s to %s: %v", b.Queue, b.Exchange, err)
+		}
+	}
+	return nil
+}
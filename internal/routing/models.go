@@ -11,3 +11,18 @@ type GameLog struct {
 	Message     string
 	Username    string
 }
+
+type TurnPhase string
+
+const (
+	TurnStart TurnPhase = "start"
+	TurnEnd   TurnPhase = "end"
+)
+
+// TurnState announces that a turn has opened for orders or closed. Orders
+// for a turn are only accepted between its start and Deadline.
+type TurnState struct {
+	Turn     int
+	Phase    TurnPhase
+	Deadline time.Time
+}
@@ -1,16 +1,54 @@
 package routing
 
 const (
-	ArmyMovesPrefix = "army_moves"
-
-	WarRecognitionsPrefix = "war"
-
 	PauseKey = "pause"
 
+	TurnKey = "turn"
+
 	GameLogSlug = "game_logs"
+
+	DeadLetterQueue = "peril_dlq"
+
+	IntentsPrefix = "intents"
+
+	EventsPrefix = "events"
+
+	IntentsQueue = IntentsPrefix
 )
 
+// The default exchange names. A deployment can rename them; see Names.
 const (
 	ExchangePerilDirect = "peril_direct"
 	ExchangePerilTopic  = "peril_topic"
+	ExchangePerilDLX    = "peril_dlx"
 )
+
+func PauseQueue(username string) string {
+	return PauseKey + "." + username
+}
+
+func TurnQueue(username string) string {
+	return TurnKey + "." + username
+}
+
+func IntentKey(username string) string {
+	return IntentsPrefix + "." + username
+}
+
+func EventsQueue(username string) string {
+	return EventsPrefix + "." + username
+}
+
+func EventKey(username string) string {
+	return EventsPrefix + "." + username
+}
+
+func GameLogKey(username string) string {
+	return GameLogSlug + "." + username
+}
+
+// AllKeys is the topic binding key matching every player's messages under
+// prefix.
+func AllKeys(prefix string) string {
+	return prefix + ".*"
+}
@@ -0,0 +1,155 @@
+package routing
+
+// Exchange kinds, as understood by every broker implementation.
+const (
+	KindDirect = "direct"
+	KindTopic  = "topic"
+	KindFanout = "fanout"
+)
+
+// ArgDeadLetterExchange is the queue argument naming the exchange rejected
+// and expired messages are republished to.
+const ArgDeadLetterExchange = "x-dead-letter-exchange"
+
+// Names are what a deployment calls the Peril exchanges, along with the
+// extra arguments, such as x-max-length, every Peril queue is declared
+// with. The broker refuses to redeclare an existing queue with different
+// arguments, so changing QueueArgs means deleting the queues first.
+type Names struct {
+	Direct     string
+	Topic      string
+	DeadLetter string
+	QueueArgs  map[string]any
+}
+
+// DefaultNames are the names Peril uses unless it is configured otherwise.
+func DefaultNames() Names {
+	return Names{
+		Direct:     ExchangePerilDirect,
+		Topic:      ExchangePerilTopic,
+		DeadLetter: ExchangePerilDLX,
+	}
+}
+
+type Exchange struct {
+	Name    string
+	Kind    string
+	Durable bool
+}
+
+type Queue struct {
+	Name       string
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+	Args       map[string]any
+}
+
+type Binding struct {
+	Queue    string
+	Exchange string
+	Key      string
+}
+
+// Topology describes exchanges, queues and the bindings between them.
+// Applying the same topology more than once is a no-op, so every binary can
+// declare what it needs on startup.
+type Topology struct {
+	Exchanges []Exchange
+	Queues    []Queue
+	Bindings  []Binding
+}
+
+// Merge returns t with everything from other appended.
+func (t Topology) Merge(other Topology) Topology {
+	return Topology{
+		Exchanges: append(append([]Exchange{}, t.Exchanges...), other.Exchanges...),
+		Queues:    append(append([]Queue{}, t.Queues...), other.Queues...),
+		Bindings:  append(append([]Binding{}, t.Bindings...), other.Bindings...),
+	}
+}
+
+// PerilTopology is the part of the topology shared by every Peril binary:
+// the game exchanges and the dead letter exchange and queue.
+func PerilTopology(n Names) Topology {
+	return Topology{
+		Exchanges: []Exchange{
+			{Name: n.Direct, Kind: KindDirect, Durable: true},
+			{Name: n.Topic, Kind: KindTopic, Durable: true},
+			{Name: n.DeadLetter, Kind: KindFanout, Durable: true},
+		},
+		Queues: []Queue{
+			{Name: DeadLetterQueue, Durable: true, Args: n.QueueArgsWith(nil)},
+		},
+		Bindings: []Binding{
+			{Queue: DeadLetterQueue, Exchange: n.DeadLetter, Key: ""},
+		},
+	}
+}
+
+// ClientTopology adds the queues a single player's client consumes from.
+func ClientTopology(n Names, username string) Topology {
+	return PerilTopology(n).Merge(Topology{
+		Queues: []Queue{
+			TransientQueue(n, PauseQueue(username)),
+			TransientQueue(n, TurnQueue(username)),
+			TransientQueue(n, EventsQueue(username)),
+		},
+		Bindings: []Binding{
+			{Queue: PauseQueue(username), Exchange: n.Direct, Key: PauseKey},
+			{Queue: TurnQueue(username), Exchange: n.Direct, Key: TurnKey},
+			{Queue: EventsQueue(username), Exchange: n.Topic, Key: AllKeys(EventsPrefix)},
+		},
+	})
+}
+
+// ServerTopology adds the queues the game server consumes from. Intents are
+// durable so that orders sent while the server restarts are not lost.
+func ServerTopology(n Names) Topology {
+	return PerilTopology(n).Merge(Topology{
+		Queues: []Queue{
+			DurableQueue(n, GameLogSlug),
+			DurableQueue(n, IntentsQueue),
+		},
+		Bindings: []Binding{
+			{Queue: GameLogSlug, Exchange: n.Topic, Key: AllKeys(GameLogSlug)},
+			{Queue: IntentsQueue, Exchange: n.Topic, Key: AllKeys(IntentsPrefix)},
+		},
+	})
+}
+
+// DurableQueue survives broker restarts and is shared by every consumer.
+func DurableQueue(n Names, name string) Queue {
+	return Queue{
+		Name:    name,
+		Durable: true,
+		Args:    n.deadLetterArgs(),
+	}
+}
+
+// TransientQueue belongs to one connection and goes away with it.
+func TransientQueue(n Names, name string) Queue {
+	return Queue{
+		Name:       name,
+		AutoDelete: true,
+		Exclusive:  true,
+		Args:       n.deadLetterArgs(),
+	}
+}
+
+// QueueArgsWith returns n's QueueArgs overridden by args, for queues such as
+// retry queues that need arguments of their own.
+func (n Names) QueueArgsWith(args map[string]any) map[string]any {
+	merged := map[string]any{}
+	for k, v := range n.QueueArgs {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (n Names) deadLetterArgs() map[string]any {
+	return n.QueueArgsWith(map[string]any{ArgDeadLetterExchange: n.DeadLetter})
+}